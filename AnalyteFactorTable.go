@@ -0,0 +1,48 @@
+package ucum
+
+
+import (
+	"strings"
+	"github.com/bertverhees/ucum/decimal"
+)
+
+/**
+AnalyteFactorTable holds analyte specific definitions of arbitrary units.
+Arbitrary units (isArbitrary="yes", e.g. [IU], [iU], [arb'U]) are not comparable by design,
+but for a known analyte an international unit corresponds to a fixed amount of substance,
+e.g. 1 [iU] of insulin is 34.7 ug.
+The Value registered for a unit code replaces the definition of that unit when converting
+a quantity of that analyte with UcumEssenceService.ConvertAnalyte; Convert ignores the table.
+Analyte names are case insensitive.
+ */
+type AnalyteFactorTable struct {
+	factors map[string]map[string]*Value
+}
+
+func NewAnalyteFactorTable() *AnalyteFactorTable {
+	t := &AnalyteFactorTable{}
+	t.factors = make(map[string]map[string]*Value)
+	t.Register("insulin", "[iU]", "ug", decimal.RequireFromString("34.7"))
+	t.Register("vitamin d", "[iU]", "ug", decimal.RequireFromString("0.025"))
+	t.Register("vitamin a", "[iU]", "ug", decimal.RequireFromString("0.3"))
+	t.Register("vitamin e", "[iU]", "mg", decimal.RequireFromString("0.67"))
+	return t
+}
+
+// registers that 1 code of analyte equals value units, e.g. 1 [iU] of insulin = 34.7 ug
+func (t *AnalyteFactorTable) Register(analyte, code, unit string, value decimal.Decimal) {
+	analyte = strings.ToLower(analyte)
+	if t.factors[analyte] == nil {
+		t.factors[analyte] = make(map[string]*Value)
+	}
+	v, _ := NewValue(unit, strings.ToUpper(unit), value)
+	t.factors[analyte][code] = v
+}
+
+func (t *AnalyteFactorTable) Exists(analyte string) bool {
+	return t.factors[strings.ToLower(analyte)] != nil
+}
+
+func (t *AnalyteFactorTable) Get(analyte string) map[string]*Value {
+	return t.factors[strings.ToLower(analyte)]
+}
@@ -0,0 +1,201 @@
+package ucum
+
+
+/**
+The translations bundled with the library, for the units most used in health care.
+English has the official names of the essence file, so it only adds the plural names.
+ */
+
+func englishTranslations() *Translations {
+	t := NewTranslations("en", ".", ",", "unity")
+	t.AddUnit("m", "meter", "meters")
+	t.AddUnit("g", "gram", "grams")
+	t.AddUnit("s", "second", "seconds")
+	t.AddUnit("l", "liter", "liters")
+	t.AddUnit("L", "liter", "liters")
+	t.AddUnit("mol", "mole", "moles")
+	t.AddUnit("min", "minute", "minutes")
+	t.AddUnit("h", "hour", "hours")
+	t.AddUnit("d", "day", "days")
+	t.AddUnit("wk", "week", "weeks")
+	t.AddUnit("mo", "month", "months")
+	t.AddUnit("a", "year", "years")
+	t.AddUnit("Cel", "degree Celsius", "degrees Celsius")
+	t.AddUnit("[degF]", "degree Fahrenheit", "degrees Fahrenheit")
+	t.AddUnit("K", "kelvin", "kelvins")
+	t.AddUnit("deg", "degree", "degrees")
+	t.AddUnit("rad", "radian", "radians")
+	t.AddUnit("Pa", "pascal", "pascals")
+	t.AddUnit("bar", "bar", "bars")
+	t.AddUnit("m[Hg]", "meter of mercury column", "meters of mercury column")
+	t.AddUnit("m[H2O]", "meter of water column", "meters of water column")
+	t.AddUnit("J", "joule", "joules")
+	t.AddUnit("cal", "calorie", "calories")
+	t.AddUnit("N", "newton", "newtons")
+	t.AddUnit("W", "watt", "watts")
+	t.AddUnit("V", "volt", "volts")
+	t.AddUnit("A", "ampère", "ampères")
+	t.AddUnit("Hz", "hertz", "hertz")
+	t.AddUnit("eq", "equivalent", "equivalents")
+	t.AddUnit("U", "unit", "units")
+	t.AddUnit("[iU]", "international unit", "international units")
+	t.AddUnit("[IU]", "international unit", "international units")
+	t.AddUnit("%", "percent", "percent")
+	t.AddUnit("[in_i]", "inch", "inches")
+	t.AddUnit("[ft_i]", "foot", "feet")
+	t.AddUnit("[lb_av]", "pound", "pounds")
+	t.AddUnit("[oz_av]", "ounce", "ounces")
+	t.AddUnit("[drp]", "drop", "drops")
+	return t
+}
+
+func dutchTranslations() *Translations {
+	t := NewTranslations("nl", ",", ".", "één")
+	addPrefixes(t, "yotta", "zetta", "exa", "peta", "tera", "giga", "mega", "kilo", "hecto", "deca",
+		"deci", "centi", "milli", "micro", "nano", "pico", "femto", "atto", "zepto", "yocto", "kibi", "mebi", "gibi", "tebi")
+	t.AddUnit("m", "meter", "meter")
+	t.AddUnit("g", "gram", "gram")
+	t.AddUnit("s", "seconde", "seconden")
+	t.AddUnit("l", "liter", "liter")
+	t.AddUnit("L", "liter", "liter")
+	t.AddUnit("mol", "mol", "mol")
+	t.AddUnit("min", "minuut", "minuten")
+	t.AddUnit("h", "uur", "uur")
+	t.AddUnit("d", "dag", "dagen")
+	t.AddUnit("wk", "week", "weken")
+	t.AddUnit("mo", "maand", "maanden")
+	t.AddUnit("a", "jaar", "jaar")
+	t.AddUnit("Cel", "graad Celsius", "graden Celsius")
+	t.AddUnit("[degF]", "graad Fahrenheit", "graden Fahrenheit")
+	t.AddUnit("K", "kelvin", "kelvin")
+	t.AddUnit("deg", "graad", "graden")
+	t.AddUnit("rad", "radiaal", "radialen")
+	t.AddUnit("Pa", "pascal", "pascal")
+	t.AddUnit("bar", "bar", "bar")
+	t.AddUnit("m[Hg]", "meter kwikkolom", "meter kwikkolom")
+	t.AddUnit("m[H2O]", "meter waterkolom", "meter waterkolom")
+	t.AddUnit("J", "joule", "joule")
+	t.AddUnit("cal", "calorie", "calorieën")
+	t.AddUnit("N", "newton", "newton")
+	t.AddUnit("W", "watt", "watt")
+	t.AddUnit("V", "volt", "volt")
+	t.AddUnit("A", "ampère", "ampère")
+	t.AddUnit("Hz", "hertz", "hertz")
+	t.AddUnit("eq", "equivalent", "equivalenten")
+	t.AddUnit("U", "eenheid", "eenheden")
+	t.AddUnit("[iU]", "internationale eenheid", "internationale eenheden")
+	t.AddUnit("[IU]", "internationale eenheid", "internationale eenheden")
+	t.AddUnit("%", "procent", "procent")
+	t.AddUnit("[in_i]", "inch", "inch")
+	t.AddUnit("[ft_i]", "voet", "voet")
+	t.AddUnit("[lb_av]", "pond", "pond")
+	t.AddUnit("[oz_av]", "ons", "ons")
+	t.AddUnit("[drp]", "druppel", "druppels")
+	t.AddUnit("10*", "het getal tien voor willekeurige machten", "")
+	t.AddUnit("[pi]", "het getal pi", "")
+	return t
+}
+
+func germanTranslations() *Translations {
+	t := NewTranslations("de", ",", ".", "Eins")
+	addPrefixes(t, "Yotta", "Zetta", "Exa", "Peta", "Tera", "Giga", "Mega", "Kilo", "Hekto", "Deka",
+		"Dezi", "Zenti", "Milli", "Mikro", "Nano", "Piko", "Femto", "Atto", "Zepto", "Yokto", "Kibi", "Mebi", "Gibi", "Tebi")
+	t.AddUnit("m", "Meter", "Meter")
+	t.AddUnit("g", "Gramm", "Gramm")
+	t.AddUnit("s", "Sekunde", "Sekunden")
+	t.AddUnit("l", "Liter", "Liter")
+	t.AddUnit("L", "Liter", "Liter")
+	t.AddUnit("mol", "Mol", "Mol")
+	t.AddUnit("min", "Minute", "Minuten")
+	t.AddUnit("h", "Stunde", "Stunden")
+	t.AddUnit("d", "Tag", "Tage")
+	t.AddUnit("wk", "Woche", "Wochen")
+	t.AddUnit("mo", "Monat", "Monate")
+	t.AddUnit("a", "Jahr", "Jahre")
+	t.AddUnit("Cel", "Grad Celsius", "Grad Celsius")
+	t.AddUnit("[degF]", "Grad Fahrenheit", "Grad Fahrenheit")
+	t.AddUnit("K", "Kelvin", "Kelvin")
+	t.AddUnit("deg", "Grad", "Grad")
+	t.AddUnit("rad", "Radiant", "Radiant")
+	t.AddUnit("Pa", "Pascal", "Pascal")
+	t.AddUnit("bar", "Bar", "Bar")
+	t.AddUnit("m[Hg]", "Meter Quecksilbersäule", "Meter Quecksilbersäule")
+	t.AddUnit("m[H2O]", "Meter Wassersäule", "Meter Wassersäule")
+	t.AddUnit("J", "Joule", "Joule")
+	t.AddUnit("cal", "Kalorie", "Kalorien")
+	t.AddUnit("N", "Newton", "Newton")
+	t.AddUnit("W", "Watt", "Watt")
+	t.AddUnit("V", "Volt", "Volt")
+	t.AddUnit("A", "Ampere", "Ampere")
+	t.AddUnit("Hz", "Hertz", "Hertz")
+	t.AddUnit("eq", "Äquivalent", "Äquivalente")
+	t.AddUnit("U", "Einheit", "Einheiten")
+	t.AddUnit("[iU]", "Internationale Einheit", "Internationale Einheiten")
+	t.AddUnit("[IU]", "Internationale Einheit", "Internationale Einheiten")
+	t.AddUnit("%", "Prozent", "Prozent")
+	t.AddUnit("[in_i]", "Zoll", "Zoll")
+	t.AddUnit("[ft_i]", "Fuß", "Fuß")
+	t.AddUnit("[lb_av]", "Pfund", "Pfund")
+	t.AddUnit("[oz_av]", "Unze", "Unzen")
+	t.AddUnit("[drp]", "Tropfen", "Tropfen")
+	t.AddUnit("10*", "die Zahl Zehn für beliebige Potenzen", "")
+	t.AddUnit("[pi]", "die Zahl Pi", "")
+	return t
+}
+
+func frenchTranslations() *Translations {
+	t := NewTranslations("fr", ",", " ", "unité")
+	addPrefixes(t, "yotta", "zetta", "exa", "péta", "téra", "giga", "méga", "kilo", "hecto", "déca",
+		"déci", "centi", "milli", "micro", "nano", "pico", "femto", "atto", "zepto", "yocto", "kibi", "mébi", "gibi", "tébi")
+	t.AddUnit("m", "mètre", "mètres")
+	t.AddUnit("g", "gramme", "grammes")
+	t.AddUnit("s", "seconde", "secondes")
+	t.AddUnit("l", "litre", "litres")
+	t.AddUnit("L", "litre", "litres")
+	t.AddUnit("mol", "mole", "moles")
+	t.AddUnit("min", "minute", "minutes")
+	t.AddUnit("h", "heure", "heures")
+	t.AddUnit("d", "jour", "jours")
+	t.AddUnit("wk", "semaine", "semaines")
+	t.AddUnit("mo", "mois", "mois")
+	t.AddUnit("a", "année", "années")
+	t.AddUnit("Cel", "degré Celsius", "degrés Celsius")
+	t.AddUnit("[degF]", "degré Fahrenheit", "degrés Fahrenheit")
+	t.AddUnit("K", "kelvin", "kelvins")
+	t.AddUnit("deg", "degré", "degrés")
+	t.AddUnit("rad", "radian", "radians")
+	t.AddUnit("Pa", "pascal", "pascals")
+	t.AddUnit("bar", "bar", "bars")
+	t.AddUnit("m[Hg]", "mètre de mercure", "mètres de mercure")
+	t.AddUnit("m[H2O]", "mètre de colonne d'eau", "mètres de colonne d'eau")
+	t.AddUnit("J", "joule", "joules")
+	t.AddUnit("cal", "calorie", "calories")
+	t.AddUnit("N", "newton", "newtons")
+	t.AddUnit("W", "watt", "watts")
+	t.AddUnit("V", "volt", "volts")
+	t.AddUnit("A", "ampère", "ampères")
+	t.AddUnit("Hz", "hertz", "hertz")
+	t.AddUnit("eq", "équivalent", "équivalents")
+	t.AddUnit("U", "unité", "unités")
+	t.AddUnit("[iU]", "unité internationale", "unités internationales")
+	t.AddUnit("[IU]", "unité internationale", "unités internationales")
+	t.AddUnit("%", "pour cent", "pour cent")
+	t.AddUnit("[in_i]", "pouce", "pouces")
+	t.AddUnit("[ft_i]", "pied", "pieds")
+	t.AddUnit("[lb_av]", "livre", "livres")
+	t.AddUnit("[oz_av]", "once", "onces")
+	t.AddUnit("[drp]", "goutte", "gouttes")
+	t.AddUnit("10*", "le nombre dix pour des puissances arbitraires", "")
+	t.AddUnit("[pi]", "le nombre pi", "")
+	return t
+}
+
+// the prefix codes in the order of the names of addPrefixes
+var translatedPrefixes = []string{"Y", "Z", "E", "P", "T", "G", "M", "k", "h", "da",
+	"d", "c", "m", "u", "n", "p", "f", "a", "z", "y", "Ki", "Mi", "Gi", "Ti"}
+
+func addPrefixes(t *Translations, names ...string) {
+	for i, code := range translatedPrefixes {
+		t.Prefixes[code] = names[i]
+	}
+}
@@ -4,12 +4,18 @@ package ucum
 import (
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 	"github.com/bertverhees/ucum/decimal"
 )
 
+// the largest exponent of a symbol the converter accepts, larger powers can not be computed in reasonable time
+const MAX_EXPONENT = 1000
+
 type Converter struct {
-	Model    *UcumModel
-	Handlers *Registry
+	Model     *UcumModel
+	Handlers  *Registry
+	Overrides map[string]*Value //definitions replacing those of the model, keyed on unit code
 }
 
 func NewConverter(model *UcumModel, handlers *Registry) *Converter {
@@ -40,16 +46,31 @@ func (c *Converter) normaliseTerm(indent string, term *Term) (*Canonical, error)
 				return nil, err
 			}
 			if div {
+				if temp.Value.Sign() == 0 {
+					return nil, fmt.Errorf("Division by zero")
+				}
 				result.DivideValueDecimal(temp.Value)
 				for _, c := range temp.Units {
 					c.Exponent = 0 - c.Exponent
 				}
+				result.AddArbitraryUnits(temp.ArbitraryUnits, -1)
+				result.addEntities(temp.entities, -1)
+				result.addKinds(temp.kinds, -1)
 			} else {
 				result.MultiplyValueDecimal(temp.Value)
+				result.AddArbitraryUnits(temp.ArbitraryUnits, 1)
+				result.addEntities(temp.entities, 1)
+				result.addKinds(temp.kinds, 1)
 			}
 			result.Units = append(result.Units, temp.Units...)
+			for _, code := range temp.RatioKinds {
+				result.addRatioKind(code)
+			}
 		} else if _, instanceof := t.Comp.(*Factor); instanceof {
 			if div {
+				if t.Comp.(*Factor).Value == 0 {
+					return nil, fmt.Errorf("Division by zero")
+				}
 				result.DivideValueInt(t.Comp.(*Factor).Value)
 			} else {
 				result.MultiplyValueInt(t.Comp.(*Factor).Value)
@@ -61,12 +82,21 @@ func (c *Converter) normaliseTerm(indent string, term *Term) (*Canonical, error)
 				return nil, err
 			}
 			if div {
+				if temp.Value.Sign() == 0 {
+					return nil, fmt.Errorf("Division by zero")
+				}
 				result.DivideValueDecimal(temp.Value)
 				for _, c := range temp.Units {
 					c.Exponent = 0 - c.Exponent
 				}
+				result.AddArbitraryUnits(temp.ArbitraryUnits, -1)
+				result.addEntities(temp.entities, -1)
+				result.addKinds(temp.kinds, -1)
 			} else {
 				result.MultiplyValueDecimal(temp.Value)
+				result.AddArbitraryUnits(temp.ArbitraryUnits, 1)
+				result.addEntities(temp.entities, 1)
+				result.addKinds(temp.kinds, 1)
 			}
 			result.Units = append(result.Units, temp.Units...)
 		}
@@ -84,24 +114,54 @@ func (c *Converter) normaliseTerm(indent string, term *Term) (*Canonical, error)
 			}
 		}
 	}
+	cancelled := make([]string, 0)
 	for i := len(result.Units) - 1; i >= 0; i-- {
 		if result.Units[i].Exponent == 0 {
+			cancelled = append(cancelled, result.Units[i].Base.Code)
 			result.RemoveFromUnits(i)
 		}
 
 	}
+	for code, exponent := range result.entities {
+		if exponent == 0 {
+			cancelled = append(cancelled, code)
+			delete(result.entities, code)
+		}
+	}
+	//the ratio kinds are the kinds of the units which cancelled out, L/L is another kind than m/m
+	kinds := false
+	for kind, count := range result.kinds {
+		if count == 0 {
+			result.addRatioKind(kind)
+			delete(result.kinds, kind)
+			kinds = true
+		}
+	}
+	if !kinds {
+		for _, code := range cancelled {
+			result.addRatioKind(code)
+		}
+	}
 	result.SortUnits()
+	result.Dimensionless = len(result.Units) == 0
 	return result, nil
 }
 
 func (c *Converter) normaliseSymbol(indent string, sym *Symbol) (*Canonical, error) {
 	result, _ := NewCanonical(decimal.New(1, 0))
+	if sym.Exponent > MAX_EXPONENT || sym.Exponent < -MAX_EXPONENT {
+		return nil, fmt.Errorf("The exponent " + strconv.Itoa(sym.Exponent) + " is out of range (maximum " + strconv.Itoa(MAX_EXPONENT) + ")")
+	}
 	bu, instanceof := sym.Unit.(*BaseUnit)
 	if instanceof {
 		cf, _ := NewCanonicalUnit(bu, sym.Exponent)
 		result.Units = append(result.Units, cf)
 	} else {
-		can, err := c.expandDefinedUnit(indent, sym.Unit.(*DefinedUnit))
+		du, instanceof := sym.Unit.(*DefinedUnit)
+		if !instanceof {
+			return nil, fmt.Errorf("Symbol without base unit or defined unit")
+		}
+		can, err := c.expandDefinedUnit(indent, du)
 		if err != nil {
 			return nil, err
 		}
@@ -109,16 +169,33 @@ func (c *Converter) normaliseSymbol(indent string, sym *Symbol) (*Canonical, err
 			c.Exponent = c.Exponent * sym.Exponent
 		}
 		result.Units = append(result.Units, can.Units...)
+		result.AddArbitraryUnits(can.ArbitraryUnits, sym.Exponent)
+		result.addEntities(can.entities, sym.Exponent)
+		//units counting entities (mol, bit) are pure numbers, they are tracked to tell mol/mol from other ratios
+		if len(can.Units) == 0 && len(can.entities) == 0 && strings.HasPrefix(du.Property, "amount of") {
+			result.entities[du.Code] = sym.Exponent
+		}
+		if du.IsArbitrary && !c.isArbitraryAlias(du) {
+			if _, overridden := c.Overrides[du.Code]; !overridden {
+				result.ArbitraryUnits[du.Code] = sym.Exponent
+			}
+		}
 		if sym.Exponent > 0 {
 			for i := 0; i < sym.Exponent; i++ {
 				result.MultiplyValueDecimal(can.Value)
 			}
 		} else {
+			if can.Value.Sign() == 0 {
+				return nil, fmt.Errorf("Division by zero: the unit " + du.Code + " has no scale")
+			}
 			for i := 0; i > sym.Exponent; i-- {
 				result.DivideValueDecimal(can.Value)
 			}
 		}
 	}
+	if kind, sign := result.kind(); kind != "" {
+		result.kinds[kind] = sign
+	}
 	if sym.Prefix != nil {
 		if sym.Exponent > 0 {
 			for i := 0; i < sym.Exponent; i++ {
@@ -134,8 +211,16 @@ func (c *Converter) normaliseSymbol(indent string, sym *Symbol) (*Canonical, err
 }
 
 func (c *Converter) expandDefinedUnit(indent string, unit *DefinedUnit) (*Canonical, error) {
+	//overrides and other handlers may change the expansion of any unit, so the precomputed canonicals do not apply
+	if can, ok := c.Model.canonicals[unit.Code]; ok && len(c.Overrides) == 0 && c.Handlers.sameAs(c.Model.canonicalHandlers) {
+		return can.Copy(), nil
+	}
 	u := unit.Value.Unit
-	if unit.IsSpecial {
+	value := unit.Value.Value
+	if override, ok := c.Overrides[unit.Code]; ok {
+		u = override.Unit
+		value = override.Value
+	} else if unit.IsSpecial {
 		if !c.Handlers.Exists(unit.Code) {
 			return nil, fmt.Errorf("Not handled yet (special unit)")
 		} else {
@@ -150,6 +235,13 @@ func (c *Converter) expandDefinedUnit(indent string, unit *DefinedUnit) (*Canoni
 	if err != nil {
 		return nil, err
 	}
-	result.MultiplyValueDecimal(unit.Value.Value)
+	result.MultiplyValueDecimal(value)
 	return result, nil
 }
+
+
+// an arbitrary unit defined as another arbitrary unit (e.g. [IU] = [iU]) is recorded as the latter
+func (c *Converter) isArbitraryAlias(unit *DefinedUnit) bool {
+	alias, instanceof := c.Model.GetUnit(unit.Value.Unit).(*DefinedUnit)
+	return instanceof && alias.IsArbitrary
+}
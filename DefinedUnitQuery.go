@@ -0,0 +1,398 @@
+package ucum
+
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/**
+DefinedUnitPredicate decides whether a defined unit is selected.
+Predicates are composed with And, Or and Not, and applied with DefinedUnitFilter.Filter.
+ */
+type DefinedUnitPredicate func(unit *DefinedUnit) bool
+
+func And(predicates ...DefinedUnitPredicate) DefinedUnitPredicate {
+	return func(unit *DefinedUnit) bool {
+		for _, p := range predicates {
+			if !p(unit) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func Or(predicates ...DefinedUnitPredicate) DefinedUnitPredicate {
+	return func(unit *DefinedUnit) bool {
+		for _, p := range predicates {
+			if p(unit) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func Not(predicate DefinedUnitPredicate) DefinedUnitPredicate {
+	return func(unit *DefinedUnit) bool {
+		return !predicate(unit)
+	}
+}
+
+func ByClass(class string) DefinedUnitPredicate {
+	return func(unit *DefinedUnit) bool {
+		return unit.Class == class
+	}
+}
+
+func ByProperty(property string) DefinedUnitPredicate {
+	return func(unit *DefinedUnit) bool {
+		return unit.Property == property
+	}
+}
+
+func ByIsMetric(isMetric bool) DefinedUnitPredicate {
+	return func(unit *DefinedUnit) bool {
+		return unit.Metric == isMetric
+	}
+}
+
+func ByIsSpecial(isSpecial bool) DefinedUnitPredicate {
+	return func(unit *DefinedUnit) bool {
+		return unit.IsSpecial == isSpecial
+	}
+}
+
+func ByIsArbitrary(isArbitrary bool) DefinedUnitPredicate {
+	return func(unit *DefinedUnit) bool {
+		return unit.IsArbitrary == isArbitrary
+	}
+}
+
+// selects the units with a name matching re
+func ByNameRegex(re *regexp.Regexp) DefinedUnitPredicate {
+	return func(unit *DefinedUnit) bool {
+		for _, name := range unit.Names {
+			if re.MatchString(name) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func HasPrintSymbol(hasPrintSymbol bool) DefinedUnitPredicate {
+	return func(unit *DefinedUnit) bool {
+		return (unit.PrintSymbol != "") == hasPrintSymbol
+	}
+}
+
+/**
+ByCanonical selects the units with the canonical units of the expression canonical,
+e.g. "g.m-3" or "kg/L" for the units of mass concentration.
+ */
+func ByCanonical(model *UcumModel, handlers *Registry, canonical string) (DefinedUnitPredicate, error) {
+	converter := NewConverter(model, handlers)
+	can, err := canonicalOf(model, converter, canonical)
+	if err != nil {
+		return nil, fmt.Errorf("ByCanonical: " + err.Error())
+	}
+	expected := ComposeExpression(can, false)
+	selected := make(map[string]bool)
+	for code, c := range unitCanonicals(model, converter) {
+		selected[code] = ComposeExpression(c, false) == expected
+	}
+	return func(unit *DefinedUnit) bool {
+		return selected[unit.Code]
+	}, nil
+}
+
+/**
+ByDimension selects the units with the dimension, written as the dimension symbols of the
+base units with their exponents (as in the essence file: L, M, T, A, C, Q, F), e.g. "M.L-3".
+"1" selects the dimensionless units. The order of the symbols does not matter.
+ */
+func ByDimension(model *UcumModel, handlers *Registry, dimension string) (DefinedUnitPredicate, error) {
+	expected, err := parseDimension(dimension)
+	if err != nil {
+		return nil, fmt.Errorf("ByDimension: " + err.Error())
+	}
+	selected := make(map[string]bool)
+	for code, c := range unitCanonicals(model, NewConverter(model, handlers)) {
+		dims := make(map[rune]int)
+		for _, cu := range c.Units {
+			dims[cu.Base.Dim] += cu.Exponent
+		}
+		selected[code] = sameDimension(dims, expected)
+	}
+	return func(unit *DefinedUnit) bool {
+		return selected[unit.Code]
+	}, nil
+}
+
+func canonicalOf(model *UcumModel, converter *Converter, unit string) (*Canonical, error) {
+	term, err := NewExpressionParser(model).Parse(unit)
+	if err != nil {
+		return nil, err
+	}
+	return converter.Convert(term)
+}
+
+/**
+returns the canonical per defined unit code, computed once when a predicate is built, so the predicate
+does not parse and convert the unit on every evaluation. Units that cannot be converted are left out.
+ */
+func unitCanonicals(model *UcumModel, converter *Converter) map[string]*Canonical {
+	result := make(map[string]*Canonical)
+	for _, unit := range model.DefinedUnits {
+		if c, err := canonicalOf(model, converter, unit.Code); err == nil {
+			result[unit.Code] = c
+		}
+	}
+	return result
+}
+
+// parses "M.L-3" to M:1, L:-3
+func parseDimension(dimension string) (map[rune]int, error) {
+	dims := make(map[rune]int)
+	dimension = strings.TrimSpace(dimension)
+	if dimension == "1" || dimension == "" {
+		return dims, nil
+	}
+	for _, part := range strings.Split(dimension, ".") {
+		runes := []rune(part)
+		if len(runes) == 0 || !unicode.IsLetter(runes[0]) {
+			return nil, fmt.Errorf("invalid dimension \"" + dimension + "\"")
+		}
+		exponent := 1
+		if len(runes) > 1 {
+			var err error
+			exponent, err = strconv.Atoi(string(runes[1:]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid exponent in dimension \"" + dimension + "\"")
+			}
+		}
+		dims[runes[0]] += exponent
+	}
+	return dims, nil
+}
+
+func sameDimension(a, b map[rune]int) bool {
+	for d, e := range a {
+		if b[d] != e {
+			return false
+		}
+	}
+	for d, e := range b {
+		if a[d] != e {
+			return false
+		}
+	}
+	return true
+}
+
+//DefinedUnitQueryParser=====================================================
+/**
+DefinedUnitQueryParser parses a textual query to a DefinedUnitPredicate, e.g.
+	class:clinical AND metric:yes AND dim:M.L-3
+	(property:length OR property:"mass concentration") AND NOT special:yes
+Terms are key:value, a value with spaces is quoted. Keys:
+- class, property: equal to the value
+- metric, special, arbitrary, printsymbol: yes/no (or true/false)
+- dim: dimension, see ByDimension
+- canonical: canonical units, see ByCanonical
+- name: regular expression on the names
+Operators are NOT, AND, OR (in order of precedence, case insensitive) and parentheses.
+ */
+type DefinedUnitQueryParser struct {
+	model    *UcumModel
+	handlers *Registry
+	tokens   []string
+	index    int
+}
+
+func NewDefinedUnitQueryParser(model *UcumModel, handlers *Registry) *DefinedUnitQueryParser {
+	p := &DefinedUnitQueryParser{}
+	p.model = model
+	p.handlers = handlers
+	return p
+}
+
+func (p *DefinedUnitQueryParser) Parse(query string) (DefinedUnitPredicate, error) {
+	tokens, err := tokenizeQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("query must not be empty")
+	}
+	p.tokens = tokens
+	p.index = 0
+	predicate, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.index < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected \"" + p.tokens[p.index] + "\" in query \"" + query + "\"")
+	}
+	return predicate, nil
+}
+
+// splits the query in words and parentheses, a quoted part is part of the word
+func tokenizeQuery(query string) ([]string, error) {
+	tokens := make([]string, 0)
+	var current strings.Builder
+	inQuotes := false
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	for _, r := range query {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+			current.WriteRune(r)
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, string(r))
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote in query \"" + query + "\"")
+	}
+	flush()
+	return tokens, nil
+}
+
+func (p *DefinedUnitQueryParser) peekKeyword(keyword string) bool {
+	return p.index < len(p.tokens) && strings.EqualFold(p.tokens[p.index], keyword)
+}
+
+func (p *DefinedUnitQueryParser) parseOr() (DefinedUnitPredicate, error) {
+	predicates := make([]DefinedUnitPredicate, 0)
+	for {
+		predicate, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, predicate)
+		if !p.peekKeyword("OR") {
+			break
+		}
+		p.index++
+	}
+	if len(predicates) == 1 {
+		return predicates[0], nil
+	}
+	return Or(predicates...), nil
+}
+
+func (p *DefinedUnitQueryParser) parseAnd() (DefinedUnitPredicate, error) {
+	predicates := make([]DefinedUnitPredicate, 0)
+	for {
+		predicate, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, predicate)
+		if !p.peekKeyword("AND") {
+			break
+		}
+		p.index++
+	}
+	if len(predicates) == 1 {
+		return predicates[0], nil
+	}
+	return And(predicates...), nil
+}
+
+func (p *DefinedUnitQueryParser) parseNot() (DefinedUnitPredicate, error) {
+	if p.peekKeyword("NOT") {
+		p.index++
+		predicate, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return Not(predicate), nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *DefinedUnitQueryParser) parsePrimary() (DefinedUnitPredicate, error) {
+	if p.index >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of query")
+	}
+	token := p.tokens[p.index]
+	p.index++
+	if token == "(" {
+		predicate, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.index >= len(p.tokens) || p.tokens[p.index] != ")" {
+			return nil, fmt.Errorf("missing \")\" in query")
+		}
+		p.index++
+		return predicate, nil
+	}
+	i := strings.Index(token, ":")
+	if i <= 0 {
+		return nil, fmt.Errorf("expected key:value in query, found \"" + token + "\"")
+	}
+	return p.parseTerm(strings.ToLower(token[:i]), token[i+1:])
+}
+
+func (p *DefinedUnitQueryParser) parseTerm(key, value string) (DefinedUnitPredicate, error) {
+	switch key {
+	case "class":
+		return ByClass(value), nil
+	case "property":
+		return ByProperty(value), nil
+	case "metric", "special", "arbitrary", "printsymbol":
+		b, err := parseYesNo(value)
+		if err != nil {
+			return nil, fmt.Errorf(key + ": " + err.Error())
+		}
+		switch key {
+		case "metric":
+			return ByIsMetric(b), nil
+		case "special":
+			return ByIsSpecial(b), nil
+		case "arbitrary":
+			return ByIsArbitrary(b), nil
+		}
+		return HasPrintSymbol(b), nil
+	case "dim":
+		return ByDimension(p.model, p.handlers, value)
+	case "canonical":
+		return ByCanonical(p.model, p.handlers, value)
+	case "name":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("name: " + err.Error())
+		}
+		return ByNameRegex(re), nil
+	}
+	return nil, fmt.Errorf("unknown key \"" + key + "\" in query")
+}
+
+func parseYesNo(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "yes", "true":
+		return true, nil
+	case "no", "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("expected yes or no, found \"" + value + "\"")
+}
@@ -3,13 +3,32 @@ package ucum
 
 import (
 	"encoding/xml"
+	"fmt"
 	"io"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"github.com/bertverhees/ucum/decimal"
 )
 
+/**
+Strict = check the essence file against the schema and the integrity of the loaded model
+(see IntegrityChecker), and return all problems found as an IntegrityError
+Validate = in strict mode, also run the UcumValidator on the loaded model, with Handlers
+(a new Registry if nil)
+ */
 type DefinitionParser struct {
+	Strict   bool
+	Validate bool
+	Handlers *Registry
+}
+
+func NewStrictDefinitionParser(validate bool) *DefinitionParser {
+	d := &DefinitionParser{}
+	d.Strict = true
+	d.Validate = validate
+	return d
 }
 
 func (d *DefinitionParser) UnmarshalTerminology(reader io.Reader) (*UcumModel, error) {
@@ -18,10 +37,29 @@ func (d *DefinitionParser) UnmarshalTerminology(reader io.Reader) (*UcumModel, e
 	if err := decoder.Decode(xmlUCUM); err != nil {
 		return nil, err
 	}
-	return xmlUCUM.UcumModel()
+	if d.Strict {
+		if problems := xmlUCUM.checkSchema(); len(problems) > 0 {
+			return nil, NewIntegrityError(problems)
+		}
+	}
+	model, err := xmlUCUM.UcumModel()
+	if err != nil || !d.Strict {
+		return model, err
+	}
+	problems := NewIntegrityChecker(model).Check()
+	if d.Validate {
+		problems = append(problems, NewUcumValidator(model, d.Handlers).Validate()...)
+	}
+	if len(problems) > 0 {
+		return nil, NewIntegrityError(problems)
+	}
+	return model, nil
 }
 
+const UCUM_NAMESPACE = "http://unitsofmeasure.org/ucum-essence"
+
 type XMLRoot struct {
+	XMLName      xml.Name
 	Version      string           `xml:"version,attr"`
 	Revision     string           `xml:"revision,attr"`
 	RevisionDate string           `xml:"revision-date,attr"`
@@ -33,13 +71,18 @@ type XMLRoot struct {
 
 func (x *XMLRoot) UcumModel() (*UcumModel, error) {
 	var err error
-	dateTime, err := x.ProcessRevisionDate(x.RevisionDate[7:32])
+	dateTime, err := x.ProcessRevisionDate(x.RevisionDate)
+	if err != nil {
+		return nil, err
+	}
+	revisionNumber, err := ParseRevision(x.Revision)
 	if err != nil {
 		return nil, err
 	}
 	ucumModel := &UcumModel{
 		Version:               	x.Version,
 		Revision:              	x.Revision,
+		RevisionNumber:        	revisionNumber,
 		RevisionDate:          	dateTime,
 		Prefixes:              	make([]*Prefix, 0),
 		BaseUnits:             	make([]*BaseUnit, 0),
@@ -53,92 +96,34 @@ func (x *XMLRoot) UcumModel() (*UcumModel, error) {
 		ClassList:				make([]string,0),
 	}
 	for _, xmlItem := range x.Prefixes {
-		names := make([]string, 0)
-		name := xmlItem.Name
-		names = append(names, name)
 		value, err := decimal.NewFromString(xmlItem.Value.Value)
 		if err!=nil {
 			return nil, err
 		}
 		prefix := &Prefix{}
-		prefix.Code = xmlItem.Code
-		prefix.CodeUC = xmlItem.CodeUC
-		prefix.Names = names
-		prefix.PrintSymbol = xmlItem.PrintSymbol
+		xmlItem.setConcept(&prefix.Concept)
 		prefix.Value = value
 		prefix.Kind = PREFIX
 		ucumModel.Prefixes = append(ucumModel.Prefixes, prefix)
 	}
 	for _, xmlItem := range x.BaseUnits {
-		names := make([]string, 0)
-		name := xmlItem.Name
-		names = append(names, name)
-		if err != nil {
-			return nil, err
-		}
 		baseUnit := &BaseUnit{}
-		baseUnit.Code = xmlItem.Code
-		baseUnit.CodeUC = xmlItem.CodeUC
-		baseUnit.Names = names
-		baseUnit.PrintSymbol = xmlItem.PrintSymbol
-		baseUnit.Property = xmlItem.Property
-		baseUnit.Dim = xmlItem.Dim
+		xmlItem.setConcept(&baseUnit.Concept)
+		baseUnit.Property = strings.TrimSpace(xmlItem.Property)
+		if dim := []rune(xmlItem.Dim); len(dim) > 0 {
+			baseUnit.Dim = dim[0]
+		}
 		baseUnit.Kind = BASEUNIT
+		ucumModel.addProperty(baseUnit.Property)
 		ucumModel.BaseUnits = append(ucumModel.BaseUnits, baseUnit)
 		ucumModel.BaseUnitsByCode[baseUnit.Code] = baseUnit
 	}
 	for _, xmlItem := range x.DefinedUnits {
-		names := make([]string, 0)
-		name := xmlItem.Name
-		names = append(names, name)
-		value := &Value{}
-		xmlItem2 := xmlItem.Value
-		value.Unit = xmlItem2.Unit
-		value.UnitUC = xmlItem2.UnitUC
-		if strings.Trim(xmlItem2.Value, " ") != "" {
-			value.Value, err = decimal.NewFromString(xmlItem2.Value)
-			if err != nil {
-				return nil, err
-			}
-		}
-		unit := &DefinedUnit{}
-		unit.Code = xmlItem.Code
-		unit.CodeUC = xmlItem.CodeUC
-		unit.Names = names
-		unit.PrintSymbol = xmlItem.PrintSymbol
-		property := strings.Trim(xmlItem.Property," ")
-		unit.Property = property
-		unit.Class = xmlItem.Class
-		unit.IsSpecial = xmlItem.IsSpecial == "yes"
-		unit.Metric = xmlItem.Metric == "yes"
-		unit.IsArbitrary = xmlItem.IsArbitrary == "yes"
-		unit.Value = value
-		unit.Kind = UNIT
-		//find property
-		found := false
-		for _,s := range ucumModel.PropertyList{
-			if s == unit.Property {
-				found = true
-				break
-			}
-		}
-		if !found {
-			ucumModel.PropertyList = append(ucumModel.PropertyList,unit.Property)
-			addSearchToIndex(ucumModel.PropertySearchIndex, unit.Property)
-		}
-		found = false
-		for _,s := range ucumModel.ClassList{
-			if s == unit.Class {
-				found = true
-				break
-			}
-		}
-		if !found {
-			ucumModel.ClassList = append(ucumModel.ClassList,unit.Class)
-			addSearchToIndex(ucumModel.ClassSearchIndex, unit.Class)
+		unit, err := xmlItem.definedUnit()
+		if err != nil {
+			return nil, err
 		}
-		ucumModel.DefinedUnits = append(ucumModel.DefinedUnits, unit)
-		ucumModel.DefinedUnitsByCode[unit.Code] = unit
+		ucumModel.addDefinedUnit(unit)
 	}
 	for _, xmlItem := range x.UcumClassInfos {
 		name := xmlItem.Name
@@ -149,24 +134,78 @@ func (x *XMLRoot) UcumModel() (*UcumModel, error) {
 		}
 		ucumModel.UcumClassInfoMap[ucumClassInfo.Name] = ucumClassInfo
 	}
+	sort.Strings(ucumModel.PropertyList)
+	ucumModel.SearchIndex = NewSearchIndex(ucumModel)
+	ucumModel.naturalParser = NewNaturalLanguageParser(ucumModel)
 	return ucumModel, err
 }
 
+// checks what the schema of the essence file requires, but the decoder does not
+func (x *XMLRoot) checkSchema() []string {
+	problems := make([]string, 0)
+	if x.XMLName.Local != "root" || x.XMLName.Space != UCUM_NAMESPACE {
+		problems = append(problems, "root element must be root in namespace "+UCUM_NAMESPACE)
+	}
+	if x.Version == "" {
+		problems = append(problems, "root has no version")
+	}
+	checkConcept := func(kind string, c XMLConcept) {
+		//CODE may be missing, "L" has none, being the case sensitive variant of "l"
+		if c.Code == "" {
+			problems = append(problems, kind+" with name "+strings.Join(c.Names, ", ")+" has no Code")
+		}
+		if len(c.Names) == 0 {
+			problems = append(problems, kind+" "+c.Code+" has no name")
+		}
+	}
+	for _, p := range x.Prefixes {
+		checkConcept("prefix", p.XMLConcept)
+		if p.Value.Value == "" {
+			problems = append(problems, "prefix "+p.Code+" has no value")
+		}
+	}
+	for _, b := range x.BaseUnits {
+		checkConcept("base-unit", b.XMLConcept)
+		if b.Dim == "" {
+			problems = append(problems, "base-unit "+b.Code+" has no dim")
+		}
+		if strings.TrimSpace(b.Property) == "" {
+			problems = append(problems, "base-unit "+b.Code+" has no property")
+		}
+	}
+	for _, u := range x.DefinedUnits {
+		checkConcept("unit", u.XMLConcept)
+		if u.Class == "" {
+			problems = append(problems, "unit "+u.Code+" has no class")
+		}
+		if strings.TrimSpace(u.Property) == "" {
+			problems = append(problems, "unit "+u.Code+" has no property")
+		}
+		if u.Value.Unit == "" {
+			problems = append(problems, "unit "+u.Code+" has no value")
+		}
+		for _, flag := range []string{u.IsSpecial, u.IsArbitrary, u.Metric} {
+			if flag != "" && flag != "yes" && flag != "no" {
+				problems = append(problems, "unit "+u.Code+" has a flag which is not yes or no: "+flag)
+			}
+		}
+	}
+	return problems
+}
+
 func addSearchToIndex(index map[string][]string, indexItem string){
 	addItem := func(i, max int, item string)bool{
 		if i < len(item)-(max-1) {
-			if index[strings.ToLower(item[i:i+max])] == nil {
-				index[item[i:i+max]] = make([]string,0)
-			}
+			key := strings.ToLower(item[i:i+max])
 			found := false
-			for _,s := range index[strings.ToLower(item[i:i+max])]{
+			for _,s := range index[key]{
 				if s == item {
 					found = true
 					break
 				}
 			}
 			if !found {
-				index[strings.ToLower(item[i:i+max])] = append(index[strings.ToLower(item[i:i+max])], item)
+				index[key] = append(index[key], item)
 			}
 			return true
 		}else {
@@ -192,30 +231,71 @@ func addSearchToIndex(index map[string][]string, indexItem string){
 	}
 }
 
+// see ParseRevisionDate
 func (x *XMLRoot) ProcessRevisionDate(revisionDate string) (time.Time, error) {
-	//add the T for correct datetime parsing
-	if strings.Index(revisionDate, "T") == -1 {
-		revisionDate = revisionDate[:10] + "T" + revisionDate[11:]
+	return ParseRevisionDate(revisionDate)
+}
+
+var revisionDateLayouts = []string{
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 -07:00",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+/**
+ParseRevisionDate parses the revision-date of the essence file. This is an SVN keyword,
+e.g. "$Date: 2017-11-21 19:04:52 -0500 (Tue, 21 Nov 2017) $", but a plain ISO date or date-time
+(e.g. "2017-11-21", "2017-11-21T19:04:52-05:00") is accepted as well.
+A missing date (empty, or the unexpanded keyword "$Date$") returns the zero time, test with IsZero.
+A date in any other format returns an error.
+ */
+func ParseRevisionDate(revisionDate string) (time.Time, error) {
+	date := stripKeyword(revisionDate, "Date")
+	//the part in parentheses is the date in words
+	if i := strings.Index(date, "("); i >= 0 {
+		date = strings.TrimSpace(date[:i])
 	}
-	//remove space between timezone and datetime
-	revisionDate = strings.Replace(revisionDate, " ", "", -1)
-	//add colon between timezone-hours and timezone-minutes
-	if len(revisionDate)>20 {
-		revisionDate = revisionDate[:22] + ":" + revisionDate[22:]
+	if date == "" {
+		return time.Time{}, nil
 	}
-	time_, err := time.Parse(time.RFC3339, revisionDate)
-	if err != nil {
-		//suppress error
-		//ucum-essence.xml has no known date-time notation.
-		//I changed it in the current file, but wrong dates may slip through in the future.
-		//This is how it should be:
-		//revision-date="$Date: 2013-10-21T21:24:43-07:00 (Mon, 21 Oct 2013) $">
-		//just normal ISO date-time notation, what else can would you expect from
-		//Regenstrief Institute, Inc.
-		//But it is: revision-date="$Date: 2015-11-13 15:13:19 -0500 (Fri, 13 Nov 2015) $
-		return time.Now(), nil
+	for _, layout := range revisionDateLayouts {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised revision-date \"" + revisionDate + "\"")
+}
+
+/**
+ParseRevision parses the revision of the essence file, the SVN keyword "$Revision: 442 $" or
+just "442", to an integer, so revisions can be compared.
+A missing revision (empty, or the unexpanded keyword "$Revision$") returns 0.
+ */
+func ParseRevision(revision string) (int, error) {
+	r := stripKeyword(revision, "Revision")
+	if r == "" {
+		return 0, nil
 	}
-	return time_, nil
+	n, err := strconv.Atoi(r)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("unrecognised revision \"" + revision + "\"")
+	}
+	return n, nil
+}
+
+// removes "$keyword:" and "$" around an SVN keyword, if present
+func stripKeyword(value, keyword string) string {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "$") && strings.HasSuffix(value, "$") && len(value) > 1 {
+		value = strings.TrimSpace(value[1 : len(value)-1])
+		if strings.HasPrefix(value, keyword) {
+			value = strings.TrimPrefix(strings.TrimPrefix(value, keyword), ":")
+		}
+	}
+	return strings.TrimSpace(value)
 }
 
 type XMLPrefix struct {
@@ -224,10 +304,29 @@ type XMLPrefix struct {
 }
 
 type XMLConcept struct {
-	Code        string `xml:"Code,attr"`
-	CodeUC      string `xml:"CODE,attr"`
-	Name        string `xml:"name"`
-	PrintSymbol string `xml:"printSymbol"`
+	Code        string    `xml:"Code,attr"`
+	CodeUC      string    `xml:"CODE,attr"`
+	Names       []string  `xml:"name"`
+	PrintSymbol XMLMarkup `xml:"printSymbol"`
+}
+
+// copies the codes, names and print symbol to the concept
+func (x *XMLConcept) setConcept(concept *Concept) {
+	concept.Code = x.Code
+	concept.CodeUC = x.CodeUC
+	concept.Names = make([]string, 0)
+	for _, name := range x.Names {
+		concept.Names = append(concept.Names, strings.TrimSpace(name))
+	}
+	if strings.TrimSpace(x.PrintSymbol.InnerXML) != "" {
+		concept.PrintSymbolMarkup = NewMarkup(x.PrintSymbol.InnerXML)
+		concept.PrintSymbol = concept.PrintSymbolMarkup.Unicode()
+	}
+}
+
+// text with markup (<sup>, <sub>, <i>, <r>), kept as it is
+type XMLMarkup struct {
+	InnerXML string `xml:",innerxml"`
 }
 
 type XMLDecimal struct {
@@ -245,7 +344,7 @@ type XMLUnit struct {
 
 type XMLBaseUnit struct {
 	XMLUnit
-	Dim rune `xml:"dim"`
+	Dim string `xml:"dim,attr"`
 }
 
 type XMLDefinedUnit struct {
@@ -257,10 +356,39 @@ type XMLDefinedUnit struct {
 	Value       XMLValue `xml:"value"`
 }
 
+func (x *XMLDefinedUnit) definedUnit() (*DefinedUnit, error) {
+	var err error
+	value := &Value{}
+	xmlItem2 := x.Value
+	if strings.TrimSpace(xmlItem2.Text) != "" {
+		value.TextMarkup = NewMarkup(xmlItem2.Text)
+		value.Text = value.TextMarkup.Unicode()
+	}
+	value.Unit = xmlItem2.Unit
+	value.UnitUC = xmlItem2.UnitUC
+	if strings.Trim(xmlItem2.Value, " ") != "" {
+		value.Value, err = decimal.NewFromString(xmlItem2.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	unit := &DefinedUnit{}
+	x.setConcept(&unit.Concept)
+	unit.Property = strings.TrimSpace(x.Property)
+	unit.Class = x.Class
+	unit.IsSpecial = x.IsSpecial == "yes"
+	unit.Metric = x.Metric == "yes"
+	unit.IsArbitrary = x.IsArbitrary == "yes"
+	unit.Value = value
+	unit.Kind = UNIT
+	return unit, nil
+}
+
 type XMLValue struct {
 	Unit   string `xml:"Unit,attr"`
 	UnitUC string `xml:"UNIT,attr"`
 	Value  string `xml:"value,attr"`
+	Text   string `xml:",innerxml"`
 }
 
 type XMLUcumClassInfo struct {
@@ -0,0 +1,17 @@
+package ucum
+
+/**
+DimensionlessPolicy decides which dimensionless quantities are comparable.
+- STRICT_DIMENSIONLESS: plane and solid angles (rad, sr) are kept distinct from pure numbers,
+all other dimensionless units (%, [ppm], 10*-6, mol/mol, g/g, annotations) are interconvertible
+- LENIENT_DIMENSIONLESS: as in SI, angles are dimensionless too (rad = 1, sr = 1)
+- RATIO_KIND_AWARE: as STRICT_DIMENSIONLESS, but ratios of different kinds (mol/mol, g/g, L/L) are not
+comparable with each other. Pure numbers (%, [ppm]) remain comparable with every ratio.
+ */
+type DimensionlessPolicy int
+
+const (
+	STRICT_DIMENSIONLESS DimensionlessPolicy = iota
+	LENIENT_DIMENSIONLESS
+	RATIO_KIND_AWARE
+)
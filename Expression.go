@@ -5,71 +5,187 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // COMPOSER==================================================================================================
 
+/**
+ExpressionComposer writes a Term as UCUM expression while it is walked, see WalkExpression.
+An operator is written when the next component is visited, so the multiplication before an annotation
+can be left out: mg{creat} is parsed as mg.{creat}, and composed as mg{creat}.
+ */
 type ExpressionComposer struct {
+	BaseExpressionVisitor
+	buffer  *bytes.Buffer
+	pending Operator
 }
 
+/**
+ComposeExpression writes a Term or a Canonical as UCUM expression, "1" for nil.
+An item which is not a Term or a Canonical is written as "?", as is a symbol without unit, and components
+which are not a Term, Symbol or Factor are left out; use ComposeExpressionErr to get an error instead.
+A parsed Term can always be composed.
+ */
 func ComposeExpression(item interface{}, canonicalValue bool) string {
 	if item == nil {
 		return "1"
 	}
 	var buffer bytes.Buffer
-	ec := &ExpressionComposer{}
+	ec := &ExpressionComposer{buffer: &buffer}
 	if _, instanceof := item.(*Term); instanceof {
-		ec.composeTerm(&buffer, item.(*Term))
+		WalkExpression(item.(*Term), ec)
+		ec.composeOp()
 	} else if _, instanceof := item.(*Canonical); instanceof {
 		ec.composeCanonical(&buffer, item.(*Canonical), canonicalValue)
 	} else {
-		panic("Can only compose expression from Term or Canonical")
+		//can only compose an expression from a Term or a Canonical
+		buffer.WriteString("?")
 	}
 	return buffer.String()
 }
 
-func (e *ExpressionComposer) composeTerm(buffer *bytes.Buffer, term *Term) {
-	if term.Comp != nil {
-		e.composeComp(buffer, term.Comp)
-	}
-	if term.Op > 0 {
-		e.composeOp(buffer, term.Op)
+// as ComposeExpression, with an error if the item is not a Term or a Canonical, or has parts which can not be composed
+func ComposeExpressionErr(item interface{}, canonicalValue bool) (string, error) {
+	switch i := item.(type) {
+	case *Term:
+		if err := checkComposable(i); err != nil {
+			return "", err
+		}
+	case *Canonical:
+		if i == nil {
+			return "", fmt.Errorf("can not compose a nil canonical")
+		}
+		for _, c := range i.Units {
+			if c == nil || c.Base == nil {
+				return "", fmt.Errorf("can not compose a canonical unit without base unit")
+			}
+		}
+	default:
+		if item != nil {
+			return "", fmt.Errorf("can only compose an expression from a Term or a Canonical, not " + fmt.Sprintf("%T", item))
+		}
 	}
-	if term.Term != nil {
-		e.composeTerm(buffer, term.Term)
-	}
-}
-func (e *ExpressionComposer) composeComp(buffer *bytes.Buffer, comp Componenter) {
-	if _, instanceof := comp.(*Factor); instanceof {
-		e.composeFactor(buffer, comp.(*Factor))
-	} else if _, instanceof := comp.(*Symbol); instanceof {
-		e.composeSymbol(buffer, comp.(*Symbol))
-	} else if _, instanceof := comp.(*Term); instanceof {
-		buffer.WriteString("(")
-		e.composeTerm(buffer, comp.(*Term))
-		buffer.WriteString(")")
-	} else {
-		buffer.WriteString("?")
+	return ComposeExpression(item, canonicalValue), nil
+}
+
+// returns an error if a component of the term is a symbol without unit, or is not a Term, Symbol or Factor
+func checkComposable(term *Term) error {
+	for t := term; t != nil; t = t.Term {
+		switch c := t.Comp.(type) {
+		case nil:
+		case *Term:
+			if err := checkComposable(c); err != nil {
+				return err
+			}
+		case *Symbol:
+			if c.Unit == nil {
+				return fmt.Errorf("can not compose a symbol without unit")
+			}
+		case *Factor:
+		default:
+			return fmt.Errorf("can not compose a component of type " + fmt.Sprintf("%T", c))
+		}
 	}
+	return nil
+}
+
+func (e *ExpressionComposer) EnterTerm(term *Term, inverted bool) bool {
+	e.composeOp()
+	e.buffer.WriteString("(")
+	return true
+}
+func (e *ExpressionComposer) LeaveTerm(term *Term, inverted bool) {
+	e.composeOp()
+	e.buffer.WriteString(")")
 }
-func (e *ExpressionComposer) composeSymbol(buffer *bytes.Buffer, symbol *Symbol) {
+func (e *ExpressionComposer) VisitSymbol(symbol *Symbol, inverted bool) {
+	e.composeOp()
+	if symbol.Unit == nil {
+		e.buffer.WriteString("?")
+		return
+	}
 	if symbol.Prefix != nil {
-		buffer.WriteString(symbol.Prefix.Code)
+		e.buffer.WriteString(symbol.Prefix.Code)
 	}
-	buffer.WriteString(symbol.Unit.GetCode())
+	e.buffer.WriteString(symbol.Unit.GetCode())
 	if symbol.Exponent != 1 {
-		buffer.WriteString(strconv.Itoa(symbol.Exponent))
+		e.buffer.WriteString(strconv.Itoa(symbol.Exponent))
+	}
+}
+func (e *ExpressionComposer) VisitFactor(factor *Factor, inverted bool) {
+	if factor.Annotation != "" {
+		//an annotation follows the component it annotates
+		if e.pending == MULTIPLICATION {
+			e.pending = 0
+		}
+		e.composeOp()
+		e.buffer.WriteString("{" + factor.Annotation + "}")
+		return
 	}
+	e.composeOp()
+	e.buffer.WriteString(strconv.Itoa(factor.Value))
 }
-func (e *ExpressionComposer) composeFactor(buffer *bytes.Buffer, factor *Factor) {
-	buffer.WriteString(strconv.Itoa(factor.Value))
+func (e *ExpressionComposer) VisitOperator(op Operator) {
+	//an operator without component, as in //m
+	e.composeOp()
+	e.pending = op
 }
-func (e *ExpressionComposer) composeOp(buffer *bytes.Buffer, op Operator) {
-	if op == DIVISION {
-		buffer.WriteString("/")
-	} else {
-		buffer.WriteString(".")
+
+// writes the pending operator, if any
+func (e *ExpressionComposer) composeOp() {
+	switch e.pending {
+	case DIVISION:
+		e.buffer.WriteString("/")
+	case MULTIPLICATION:
+		e.buffer.WriteString(".")
 	}
+	e.pending = 0
+}
+
+// true if the term starts with an annotation
+func isAnnotation(term *Term) bool {
+	if term == nil {
+		return false
+	}
+	factor, instanceof := term.Comp.(*Factor)
+	return instanceof && factor.Annotation != ""
+}
+
+/**
+EqualTerms is true if the terms have the same structure: the same components (units, prefixes, exponents,
+factors and annotations) combined by the same operators, in the same order.
+Parse(ComposeExpression(term)) is equal to term for every parsed term.
+ */
+func EqualTerms(a, b *Term) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Op != b.Op || !equalComponents(a.Comp, b.Comp) {
+		return false
+	}
+	return EqualTerms(a.Term, b.Term)
+}
+
+func equalComponents(a, b Componenter) bool {
+	switch x := a.(type) {
+	case *Term:
+		y, instanceof := b.(*Term)
+		return instanceof && EqualTerms(x, y)
+	case *Symbol:
+		y, instanceof := b.(*Symbol)
+		if !instanceof || x.Exponent != y.Exponent || x.HasPrefix() != y.HasPrefix() {
+			return false
+		}
+		if x.HasPrefix() && x.Prefix.Code != y.Prefix.Code {
+			return false
+		}
+		return (x.Unit == nil && y.Unit == nil) || (x.Unit != nil && y.Unit != nil && x.Unit.GetCode() == y.Unit.GetCode())
+	case *Factor:
+		y, instanceof := b.(*Factor)
+		return instanceof && x.Value == y.Value && x.Annotation == y.Annotation
+	}
+	return a == nil && b == nil
 }
 func (e *ExpressionComposer) composeCanonical(buffer *bytes.Buffer, can *Canonical, canonicalValue bool) {
 	if canonicalValue {
@@ -91,65 +207,153 @@ func (e *ExpressionComposer) composeCanonical(buffer *bytes.Buffer, can *Canonic
 
 // FORMALSTRUCTTURECOMPOSER================================================================================================
 
+/**
+FormalStructureComposer writes a Term as formal structure while it is walked, see WalkExpression.
+Translations names the prefixes and units, their official (English) names if nil.
+Plural is true if the first unit of the numerator is named in plural, as in "2 (milligrams) / (deciliter)".
+Annotations are left out, they do not change the unit: mg{total} is "(milligram)", {cells}/uL is "/ (microliter)".
+ */
 type FormalStructureComposer struct {
+	BaseExpressionVisitor
+	Translations *Translations
+	Plural       bool
+	buffer       *bytes.Buffer
+	pending      Operator //the operator before the next component
+	written      bool     //true if a component has been written in the current (nested) term
+}
+
+/**
+GenerateDisplay returns the human display name of a unit expression, as in the displayNameGeneration
+cases of the functional tests: "(unity)" for the empty expression, else the formal structure, e.g.
+"(milligram) / (deciliter)". Annotations are left out: mg{total} is "(milligram)", /min is "/ (minute)"
+and {cells} is "(unity)".
+ */
+func GenerateDisplay(model *UcumModel, unit string) (string, error) {
+	return GenerateLocalisedDisplay(model, unit, nil)
+}
+
+// as GenerateDisplay, with the names of translations, e.g. "(milligram) / (deciliter)" in Dutch
+func GenerateLocalisedDisplay(model *UcumModel, unit string, translations *Translations) (string, error) {
+	unity := "(unity)"
+	if translations != nil {
+		unity = "(" + translations.Unity + ")"
+	}
+	if unit == "" {
+		return unity, nil
+	}
+	term, err := NewExpressionParser(model).Parse(unit)
+	if err != nil {
+		return "", err
+	}
+	if display := ComposeLocalisedStructure(term, translations, false); display != "1" {
+		return display, nil
+	}
+	//only annotations, as in {cells}
+	return unity, nil
 }
 
 func ComposeFormalStructure(term *Term) string {
+	return ComposeLocalisedStructure(term, nil, false)
+}
+
+// composes the formal structure with the names of translations, the first unit in plural if plural is true
+func ComposeLocalisedStructure(term *Term, translations *Translations, plural bool) string {
 	var buffer bytes.Buffer
-	ec := &FormalStructureComposer{}
-	ec.composeTerm(&buffer, term)
+	ec := &FormalStructureComposer{Translations: translations, Plural: plural, buffer: &buffer}
+	WalkExpression(term, ec)
+	if buffer.Len() == 0 {
+		//only annotations, as in {cells}
+		return "1"
+	}
 	return buffer.String()
 }
 
-func (e *FormalStructureComposer) composeTerm(buffer *bytes.Buffer, term *Term) {
-	if term.Comp != nil {
-		e.composeComp(buffer, term.Comp)
-	}
-	if term.Op > 0 {
-		e.composeOp(buffer, term.Op)
-	}
+// a nested term comes from parentheses, which are kept, as in "(milligram) / ((kilogram) * (day))"
+func (e *FormalStructureComposer) EnterTerm(term *Term, inverted bool) bool {
+	e.composeOp()
 	if term.Term != nil {
-		e.composeTerm(buffer, term.Term)
+		e.buffer.WriteString("(")
 	}
+	e.written = false
+	return true
 }
-func (e *FormalStructureComposer) composeComp(buffer *bytes.Buffer, comp Componenter) {
-	if _, instanceof := comp.(*Factor); instanceof {
-		e.composeFactor(buffer, comp.(*Factor))
-	} else if _, instanceof := comp.(*Symbol); instanceof {
-		e.composeSymbol(buffer, comp.(*Symbol))
-	} else if _, instanceof := comp.(*Term); instanceof {
-		e.composeTerm(buffer, comp.(*Term))
+func (e *FormalStructureComposer) LeaveTerm(term *Term, inverted bool) {
+	if term.Term != nil {
+		e.buffer.WriteString(")")
+	}
+	e.pending = 0
+	e.written = true
+}
+func (e *FormalStructureComposer) VisitSymbol(symbol *Symbol, inverted bool) {
+	e.composeOp()
+	e.written = true
+	e.buffer.WriteString("(")
+	if e.Translations != nil {
+		//a unit in the denominator stays singular: "2 (milligrams) / (deciliter)"
+		plural := e.Plural && !inverted
+		e.buffer.WriteString(e.Translations.SymbolName(symbol, plural))
+		if plural {
+			e.Plural = false
+		}
 	} else {
-		buffer.WriteString("?")
+		if symbol.Prefix != nil {
+			e.buffer.WriteString(displayName(symbol.Prefix))
+		}
+		e.buffer.WriteString(displayName(symbol.Unit))
 	}
+	if symbol.Exponent != 1 {
+		e.buffer.WriteString(" ^ ")
+		e.buffer.WriteString(strconv.Itoa(symbol.Exponent))
+	}
+	e.buffer.WriteString(")")
 }
-func (e *FormalStructureComposer) composeSymbol(buffer *bytes.Buffer, symbol *Symbol) {
-	buffer.WriteString("(")
-	if symbol.Prefix != nil {
-		buffer.WriteString(symbol.Prefix.Names[0])
+// the first name of the concept, its code if it has no names
+func displayName(concept Concepter) string {
+	if name := firstName(concept); name != "" {
+		return name
 	}
-	buffer.WriteString(symbol.Unit.GetNames()[0])
-	if symbol.Exponent != 1 {
-		buffer.WriteString(" ^ ")
-		buffer.WriteString(strconv.Itoa(symbol.Exponent))
+	return concept.GetCode()
+}
+
+func (e *FormalStructureComposer) VisitFactor(factor *Factor, inverted bool) {
+	if factor.Annotation != "" {
+		//the operator before an annotation only applies to the annotation
+		e.pending = 0
+		return
 	}
-	buffer.WriteString(")")
+	e.composeOp()
+	e.written = true
+	e.buffer.WriteString(strconv.Itoa(factor.Value))
 }
-func (e *FormalStructureComposer) composeFactor(buffer *bytes.Buffer, factor *Factor) {
-	buffer.WriteString(strconv.Itoa(factor.Value))
+func (e *FormalStructureComposer) VisitOperator(op Operator) {
+	//an operator without component, as in //m
+	e.composeOp()
+	e.pending = op
 }
-func (e *FormalStructureComposer) composeOp(buffer *bytes.Buffer, op Operator) {
-	if op == DIVISION {
-		buffer.WriteString(" / ")
-	} else {
-		buffer.WriteString(" * ")
+
+// writes the pending operator, if any. A leading solidus is written without space before it: "/ (minute)"
+func (e *FormalStructureComposer) composeOp() {
+	switch {
+	case e.pending == DIVISION && e.written:
+		e.buffer.WriteString(" / ")
+	case e.pending == DIVISION:
+		e.buffer.WriteString("/ ")
+	case e.pending == MULTIPLICATION && e.written:
+		e.buffer.WriteString(" * ")
 	}
+	e.pending = 0
 }
 
 // PARSER==================================================================================================
 
+/**
+Lenient = replace the Unicode look-alikes of ASCII codes before parsing (see ReplaceLookalikes),
+e.g. µg is parsed as ug. ParseLenient also returns the replacements made.
+A parser keeps no state between calls, it may be shared between goroutines.
+ */
 type ExpressionParser struct {
-	Model *UcumModel
+	Model   *UcumModel
+	Lenient bool
 }
 
 func NewExpressionParser(model *UcumModel) *ExpressionParser {
@@ -158,9 +362,38 @@ func NewExpressionParser(model *UcumModel) *ExpressionParser {
 	return e
 }
 
+func NewLenientExpressionParser(model *UcumModel) *ExpressionParser {
+	e := NewExpressionParser(model)
+	e.Lenient = true
+	return e
+}
+
 func (p *ExpressionParser) Parse(code string) (*Term, error) {
+	if p.Lenient {
+		term, _, err := p.ParseLenient(code)
+		return term, err
+	}
+	return p.parse(code)
+}
+
+// parses the code with the Unicode look-alikes replaced, whether the parser is Lenient or not, and returns the replacements made
+func (p *ExpressionParser) ParseLenient(code string) (*Term, []*Lookalike, error) {
+	code, replacements, err := ReplaceLookalikes(code)
+	if err != nil {
+		return nil, nil, err
+	}
+	term, err := p.parse(code)
+	if err != nil {
+		return nil, nil, err
+	}
+	return term, replacements, nil
+}
+
+func (p *ExpressionParser) parse(code string) (*Term, error) {
 	l := NewLexer(code)
-	l.Consume()
+	if err := l.Consume(); err != nil {
+		return nil, err
+	}
 	res, err := p.parseTerm(l, true)
 	if err != nil {
 		return nil, err
@@ -178,15 +411,19 @@ func (p *ExpressionParser) parseTerm(l *Lexer, first bool) (*Term, error) {
 		res.Comp = NewFactor(1)
 	} else if l.TokenType == SOLIDUS {
 		res.Op = DIVISION
-		l.Consume()
+		if err := l.Consume(); err != nil {
+			return nil, err
+		}
 		res.Term, err = p.parseTerm(l, false)
 		if err != nil {
 			return nil, err
 		}
 	} else {
 		if l.TokenType == ANNOTATION {
-			res.Comp = NewFactor(1)
-			l.Consume()
+			res.Comp = NewAnnotationFactor(strings.TrimSuffix(l.Token, "}"))
+			if err := l.Consume(); err != nil {
+				return nil, err
+			}
 		} else {
 			res.Comp, err = p.parseComp(l)
 			if err != nil {
@@ -199,10 +436,14 @@ func (p *ExpressionParser) parseTerm(l *Lexer, first bool) (*Term, error) {
 			//While the multiplication operator (.) must appear between two unit terms, the division operator (/) may appear at the beginning of the expression, indicating inversion of the following term.
 			if l.TokenType == SOLIDUS {
 				res.Op = DIVISION
-				l.Consume()
+				if err := l.Consume(); err != nil {
+					return nil, err
+				}
 			} else if l.TokenType == PERIOD {
 				res.Op = MULTIPLICATION
-				l.Consume()
+				if err := l.Consume(); err != nil {
+					return nil, err
+				}
 			} else if l.TokenType == ANNOTATION {
 				res.Op = MULTIPLICATION
 			} else {
@@ -221,7 +462,9 @@ func (p *ExpressionParser) parseComp(l *Lexer) (Componenter, error) {
 			return nil, fmt.Errorf("Error processing Tolen as numb er '" + l.Source + "': " + "The token '" + l.Token + "' is cannot be converted to integer" + " at position " + strconv.Itoa(l.Start))
 		}
 		fact := NewFactor(f)
-		l.Consume()
+		if err := l.Consume(); err != nil {
+			return nil, err
+		}
 		return fact, nil
 	} else if l.TokenType == SYMBOL {
 		return p.parseSymbol(l)
@@ -230,13 +473,17 @@ func (p *ExpressionParser) parseComp(l *Lexer) (Componenter, error) {
 		//Parentheses may be used to override normal left-to-right evaluation of an expreession.
 		// For example kg/m.s2 divides kg by m and multiplies the result by s2. kg/(m.s2) multiplies m by s2 and divides that by kg.
 	} else if l.TokenType == OPEN {
-		l.Consume()
+		if err := l.Consume(); err != nil {
+			return nil, err
+		}
 		res, err := p.parseTerm(l, true)
 		if err != nil {
 			return nil, err
 		}
 		if l.TokenType == CLOSE {
-			l.Consume()
+			if err := l.Consume(); err != nil {
+				return nil, err
+			}
 		} else {
 			return nil, fmt.Errorf("Error processing unit '" + l.Source + "': " + "Unexpected Token Type '" + l.TokenType.String() + "' looking for a close bracket" + "' at position " + strconv.Itoa(l.Start))
 		}
@@ -271,19 +518,23 @@ func (p *ExpressionParser) parseSymbol(l *Lexer) (Componenter, error) {
 		unit = p.Model.GetUnit(sym)
 		if unit != nil {
 			symbol.Unit = unit
-		} else if sym != "1" {
+		} else {
 			return nil, fmt.Errorf("Error processing unit '" + l.Source + "': " + "The unit '" + sym + "' is unknown" + " at position " + strconv.Itoa(l.Start))
 		}
 	}
 
-	l.Consume()
+	if err := l.Consume(); err != nil {
+		return nil, err
+	}
 	if l.TokenType == NUMBER {
 		var err error
 		symbol.Exponent, err = l.TokenAsInt()
 		if err != nil {
 			return nil, fmt.Errorf("Error processing Token as number '" + l.Source + "': " + "The token '" + l.Token + "' is cannot be converted to integer" + " at position " + strconv.Itoa(l.Start))
 		}
-		l.Consume()
+		if err := l.Consume(); err != nil {
+			return nil, err
+		}
 	} else {
 		symbol.Exponent = 1
 	}
@@ -294,6 +545,11 @@ func (p *ExpressionParser) parseSymbol(l *Lexer) (Componenter, error) {
 
 const NO_CHAR = 0
 
+/**
+The lexer works on the bytes of Source, Index and Start are byte positions.
+UCUM codes are ASCII only, a non ASCII character is reported as UnicodeError,
+with the ASCII code to write instead if it is a known look-alike (see FindLookalike)
+ */
 type Lexer struct {
 	Source    string
 	Index     int
@@ -314,6 +570,9 @@ func (l *Lexer) Consume() error {
 	l.TokenType = NONE
 	l.Start = l.Index
 	if l.Index < len(l.Source) {
+		if l.Source[l.Index] >= utf8.RuneSelf {
+			return NewUnicodeError(l.Source, FindLookalike(l.Source, l.Index))
+		}
 		ch := l.nextChar()
 		checkAnnotation, err := l.checkAnnotation(ch)
 		if err != nil {
@@ -338,24 +597,19 @@ func (l *Lexer) Consume() error {
 			checkAnnotation ||
 			checkNumber ||
 			checkNumberOrSymbol) {
-				return fmt.Errorf("Error processing unit '" + l.Source + "': unexpected character '" + string(ch) + "' at position " + strconv.Itoa(l.Start))
+				return fmt.Errorf("Error processing unit '" + l.Source + "': unexpected character " + strconv.QuoteRune(rune(ch)) + " at position " + strconv.Itoa(l.Start))
 		}
 	}
 	return nil
 }
 
-func (l *Lexer) nextChar() rune {
-	var r rune
-	if l.Index < len(l.Source) {
-		r = []rune(l.Source)[l.Index]
-	} else {
-		r = NO_CHAR
-	}
+func (l *Lexer) nextChar() byte {
+	ch := l.peekChar()
 	l.Index++
-	return r
+	return ch
 }
 
-func (l *Lexer) checkSingleChar(ch rune, test rune, tokenType TokenType) bool {
+func (l *Lexer) checkSingleChar(ch byte, test byte, tokenType TokenType) bool {
 	if ch == test {
 		l.Token = string(ch)
 		l.TokenType = tokenType
@@ -364,7 +618,7 @@ func (l *Lexer) checkSingleChar(ch rune, test rune, tokenType TokenType) bool {
 	return false
 }
 
-func (l *Lexer) checkAnnotation(ch rune) (bool, error) {
+func (l *Lexer) checkAnnotation(ch byte) (bool, error) {
 	if ch == '{' {
 		b := ""
 		for {
@@ -372,12 +626,15 @@ func (l *Lexer) checkAnnotation(ch rune) (bool, error) {
 				break
 			}
 			ch = l.nextChar()
-			if !IsAsciiChar(ch) {
-				return false, fmt.Errorf("Error processing unit'" + l.Source + "': Annotation contains non-ascii characters")
-			}
 			if ch == NO_CHAR {
 				return false, fmt.Errorf("Error processing unit'" + l.Source + "': unterminated annotation")
 			}
+			if ch >= utf8.RuneSelf {
+				return false, NewUnicodeError(l.Source, FindLookalike(l.Source, l.Index-1))
+			}
+			if !IsAsciiChar(rune(ch)) {
+				return false, fmt.Errorf("Error processing unit'" + l.Source + "': Annotation contains non-ascii characters")
+			}
 			b = b + string(ch)
 		}
 		l.Token = b
@@ -387,7 +644,7 @@ func (l *Lexer) checkAnnotation(ch rune) (bool, error) {
 	return false, nil
 }
 
-func (l *Lexer) checkNumber(ch rune) (bool, error) {
+func (l *Lexer) checkNumber(ch byte) (bool, error) {
 	if ch == '+' || ch == '-' {
 		l.Token = string(ch)
 		ch = l.peekChar()
@@ -400,7 +657,7 @@ func (l *Lexer) checkNumber(ch rune) (bool, error) {
 			ch = l.peekChar()
 		}
 		if len(l.Token) == 1 {
-			return false, fmt.Errorf("Error processing unit'" + l.Source + "': unexpected character '" + string(ch) + "' at position " + strconv.Itoa(l.Start) + ": a + or - must be followed by at least one digit")
+			return false, fmt.Errorf("Error processing unit'" + l.Source + "': unexpected character " + strconv.QuoteRune(rune(ch)) + " at position " + strconv.Itoa(l.Start) + ": a + or - must be followed by at least one digit")
 		}
 		l.TokenType = NUMBER
 		return true, nil
@@ -408,7 +665,7 @@ func (l *Lexer) checkNumber(ch rune) (bool, error) {
 	return false, nil
 }
 
-func (l *Lexer) checkNumberOrSymbol(ch rune) (bool, error) {
+func (l *Lexer) checkNumberOrSymbol(ch byte) (bool, error) {
 	var err error
 	isSymbol := false
 	isInBrackets := false
@@ -437,6 +694,9 @@ func (l *Lexer) checkNumberOrSymbol(ch rune) (bool, error) {
 				return false, err
 			}
 		}
+		if isInBrackets && ch >= utf8.RuneSelf {
+			return false, NewUnicodeError(l.Source, FindLookalike(l.Source, l.Index))
+		}
 		if isSymbol {
 			l.TokenType = SYMBOL
 		} else {
@@ -447,7 +707,7 @@ func (l *Lexer) checkNumberOrSymbol(ch rune) (bool, error) {
 	return false, nil
 }
 
-func (l *Lexer) checkBrackets(ch rune, isInBrackets bool) (bool, error) {
+func (l *Lexer) checkBrackets(ch byte, isInBrackets bool) (bool, error) {
 	if ch == '[' {
 		if isInBrackets {
 			return false, fmt.Errorf("Error processing unit '" + l.Source + "': " + "Nested [" + "' at position " + strconv.Itoa(l.Start))
@@ -465,26 +725,26 @@ func (l *Lexer) checkBrackets(ch rune, isInBrackets bool) (bool, error) {
 	return isInBrackets, nil
 }
 
-func (l *Lexer) isValidSymbolChar(ch rune, allowDigits, isInBrackets bool) bool {
+func (l *Lexer) isValidSymbolChar(ch byte, allowDigits, isInBrackets bool) bool {
 	return (allowDigits && ch >= '0' && ch <= '9') || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '[' ||
 		ch == ']' || ch == '%' || ch == '*' || ch == '^' || ch == '\'' || ch == '"' || ch == '_' || (isInBrackets && ch == '.') || (isInBrackets && ch == '/') || (isInBrackets && ch == '(') || (isInBrackets && ch == ')')
 }
 
-func (l *Lexer) peekChar() rune {
-	var r rune
+func (l *Lexer) peekChar() byte {
 	if l.Index < len(l.Source) {
-		r = []rune(l.Source)[l.Index]
-	} else {
-		r = NO_CHAR
+		return l.Source[l.Index]
 	}
-	return r
+	return NO_CHAR
 }
 
 func (l *Lexer) Finished() bool {
-	return l.Index == len(l.Source)
+	return l.Index >= len(l.Source)
 }
 
 func (l *Lexer) TokenAsInt() (int, error) {
+	if l.Token == "" {
+		return 0, fmt.Errorf("Error processing unit '" + l.Source + "': expected a number at position " + strconv.Itoa(l.Start))
+	}
 	if l.Token[0] == '+' {
 		result, err := strconv.Atoi(l.Token[1:])
 		if err != nil {
@@ -0,0 +1,289 @@
+package ucum
+
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/bertverhees/ucum/decimal"
+)
+
+/**
+ExpressionVisitor visits the components of a parsed Term, see WalkExpression.
+inverted is true if the component divides, taking the enclosing terms into account:
+in mg/(kg.d), mg is not inverted, kg and d are.
+EnterTerm and LeaveTerm are called for a nested term (a term between parentheses),
+if EnterTerm returns false, the components of the nested term are not visited.
+VisitOperator is called for each operator, in the order of the expression.
+ */
+type ExpressionVisitor interface {
+	EnterTerm(term *Term, inverted bool) bool
+	LeaveTerm(term *Term, inverted bool)
+	VisitSymbol(symbol *Symbol, inverted bool)
+	VisitFactor(factor *Factor, inverted bool)
+	VisitOperator(op Operator)
+}
+
+// BaseExpressionVisitor does nothing, embed it to implement only the methods needed
+type BaseExpressionVisitor struct {
+}
+
+func (v *BaseExpressionVisitor) EnterTerm(term *Term, inverted bool) bool {
+	return true
+}
+func (v *BaseExpressionVisitor) LeaveTerm(term *Term, inverted bool) {
+}
+func (v *BaseExpressionVisitor) VisitSymbol(symbol *Symbol, inverted bool) {
+}
+func (v *BaseExpressionVisitor) VisitFactor(factor *Factor, inverted bool) {
+}
+func (v *BaseExpressionVisitor) VisitOperator(op Operator) {
+}
+
+// visits the components and operators of the term in the order of the expression
+func WalkExpression(term *Term, visitor ExpressionVisitor) {
+	walkTerm(term, false, visitor)
+}
+
+// an operator only applies to the next component, a/b.c is a.b-1.c
+func walkTerm(term *Term, inverted bool, visitor ExpressionVisitor) {
+	div := false
+	for t := term; t != nil; t = t.Term {
+		componentInverted := inverted != div
+		switch c := t.Comp.(type) {
+		case *Term:
+			if visitor.EnterTerm(c, componentInverted) {
+				walkTerm(c, componentInverted, visitor)
+			}
+			visitor.LeaveTerm(c, componentInverted)
+		case *Symbol:
+			visitor.VisitSymbol(c, componentInverted)
+		case *Factor:
+			visitor.VisitFactor(c, componentInverted)
+		}
+		if t.Op > 0 {
+			visitor.VisitOperator(t.Op)
+		}
+		div = t.Op == DIVISION
+	}
+}
+
+//Helpers=====================================================
+
+// returns a copy of the term, the units and prefixes of the symbols are shared
+func CopyTerm(term *Term) *Term {
+	if term == nil {
+		return nil
+	}
+	result := &Term{}
+	switch c := term.Comp.(type) {
+	case *Term:
+		result.Comp = CopyTerm(c)
+	case *Symbol:
+		result.Comp, _ = NewSymbol(c.Unit, c.Prefix, c.Exponent)
+	case *Factor:
+		factor := NewFactor(c.Value)
+		factor.Annotation = c.Annotation
+		result.Comp = factor
+	}
+	result.Op = term.Op
+	result.Term = CopyTerm(term.Term)
+	return result
+}
+
+type symbolCollector struct {
+	BaseExpressionVisitor
+	symbols []*Symbol
+}
+
+func (v *symbolCollector) VisitSymbol(symbol *Symbol, inverted bool) {
+	v.symbols = append(v.symbols, symbol)
+}
+
+func collectSymbols(term *Term) []*Symbol {
+	collector := &symbolCollector{}
+	collector.symbols = make([]*Symbol, 0)
+	WalkExpression(term, collector)
+	return collector.symbols
+}
+
+// returns the units of the term, without prefix, each unit once, in the order of the expression
+func CollectAtoms(term *Term) []Uniter {
+	result := make([]Uniter, 0)
+	found := make(map[string]bool)
+	for _, symbol := range collectSymbols(term) {
+		if !found[symbol.Unit.GetCode()] {
+			found[symbol.Unit.GetCode()] = true
+			result = append(result, symbol.Unit)
+		}
+	}
+	return result
+}
+
+// returns the prefixes of the term, each prefix once, in the order of the expression
+func CollectPrefixes(term *Term) []*Prefix {
+	result := make([]*Prefix, 0)
+	found := make(map[string]bool)
+	for _, symbol := range collectSymbols(term) {
+		if symbol.Prefix != nil && !found[symbol.Prefix.Code] {
+			found[symbol.Prefix.Code] = true
+			result = append(result, symbol.Prefix)
+		}
+	}
+	return result
+}
+
+// returns a copy of the term in which the unit with the code is replaced by unit, prefixes and exponents are kept
+func ReplaceAtom(term *Term, code string, unit Uniter) *Term {
+	result := CopyTerm(term)
+	for _, symbol := range collectSymbols(result) {
+		if symbol.Unit.GetCode() == code {
+			symbol.Unit = unit
+		}
+	}
+	return result
+}
+
+/**
+returns a copy of the term which is its inverse: every component which multiplies divides and vice versa,
+mg/dL becomes /mg.dL, /min becomes min
+ */
+func InvertTerm(term *Term) *Term {
+	result := CopyTerm(term)
+	for t := result; t != nil; t = t.Term {
+		if t.Term != nil {
+			if t.Op == DIVISION {
+				t.Op = MULTIPLICATION
+			} else {
+				t.Op = DIVISION
+			}
+		}
+	}
+	//the first component, a leading solidus is removed or added
+	if result.Comp == nil {
+		if result.Term == nil {
+			return &Term{Comp: NewFactor(1)}
+		}
+		return result.Term
+	}
+	return &Term{Op: DIVISION, Term: result}
+}
+
+//FlatTerm=====================================================
+/**
+FlatTerm is a term as a product of a factor and symbols with exponents,
+(Numerator / Denominator) * symbol1^exponent1 * symbol2^exponent2 ...
+A symbol (prefix and unit) occurs once, symbols with exponent 0 are dropped, the factor is reduced.
+ */
+type FlatTerm struct {
+	Numerator   int
+	Denominator int
+	Symbols     []*Symbol
+}
+
+type flattener struct {
+	BaseExpressionVisitor
+	flat *FlatTerm
+	err  error
+}
+
+func (v *flattener) VisitFactor(factor *Factor, inverted bool) {
+	product := &v.flat.Numerator
+	if inverted {
+		product = &v.flat.Denominator
+	}
+	result := *product * factor.Value
+	if *product != 0 && result/(*product) != factor.Value {
+		if v.err == nil {
+			v.err = fmt.Errorf("the factors of the term overflow, " + strconv.Itoa(*product) + " * " + strconv.Itoa(factor.Value))
+		}
+		return
+	}
+	*product = result
+}
+
+func (v *flattener) VisitSymbol(symbol *Symbol, inverted bool) {
+	exponent := symbol.Exponent
+	if inverted {
+		exponent = -exponent
+	}
+	for _, s := range v.flat.Symbols {
+		if s.Unit.GetCode() == symbol.Unit.GetCode() && s.HasPrefix() == symbol.HasPrefix() &&
+			(!s.HasPrefix() || s.Prefix.Code == symbol.Prefix.Code) {
+			s.Exponent += exponent
+			return
+		}
+	}
+	s, _ := NewSymbol(symbol.Unit, symbol.Prefix, exponent)
+	v.flat.Symbols = append(v.flat.Symbols, s)
+}
+
+// flattens the term, the symbols are in the order of their first occurrence; an error if the product of the factors overflows
+func FlattenTerm(term *Term) (*FlatTerm, error) {
+	v := &flattener{}
+	v.flat = &FlatTerm{Numerator: 1, Denominator: 1, Symbols: make([]*Symbol, 0)}
+	WalkExpression(term, v)
+	if v.err != nil {
+		return nil, v.err
+	}
+	symbols := make([]*Symbol, 0)
+	for _, s := range v.flat.Symbols {
+		if s.Exponent != 0 {
+			symbols = append(symbols, s)
+		}
+	}
+	v.flat.Symbols = symbols
+	if d := gcd(v.flat.Numerator, v.flat.Denominator); d > 1 {
+		v.flat.Numerator /= d
+		v.flat.Denominator /= d
+	}
+	return v.flat, nil
+}
+
+func gcd(a, b int) int {
+	if a < 0 {
+		a = -a
+	}
+	if b < 0 {
+		b = -b
+	}
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// the value of the factor, an error if the term divides by zero
+func (f *FlatTerm) GetValue() (decimal.Decimal, error) {
+	if f.Denominator == 0 {
+		return decimal.Decimal{}, fmt.Errorf("Division by zero")
+	}
+	return decimal.New(int64(f.Numerator), 0).Div(decimal.New(int64(f.Denominator), 0)), nil
+}
+
+// returns the flat term as Term: the factor, the symbols multiplied (with negative exponents), divided by the denominator
+func (f *FlatTerm) ToTerm() *Term {
+	components := make([]Componenter, 0)
+	if f.Numerator != 1 {
+		components = append(components, NewFactor(f.Numerator))
+	}
+	for _, s := range f.Symbols {
+		symbol, _ := NewSymbol(s.Unit, s.Prefix, s.Exponent)
+		components = append(components, symbol)
+	}
+	if len(components) == 0 {
+		components = append(components, NewFactor(1))
+	}
+	result := &Term{Comp: components[0]}
+	last := result
+	for _, c := range components[1:] {
+		last.Op = MULTIPLICATION
+		last.Term = &Term{Comp: c}
+		last = last.Term
+	}
+	if f.Denominator != 1 {
+		last.Op = DIVISION
+		last.Term = &Term{Comp: NewFactor(f.Denominator)}
+	}
+	return result
+}
@@ -0,0 +1,134 @@
+package ucum
+
+
+import (
+	"strings"
+)
+
+/**
+IntegrityError holds all problems found by a strict load of an essence file,
+see DefinitionParser.Strict.
+ */
+type IntegrityError struct {
+	Problems []string
+}
+
+func NewIntegrityError(problems []string) *IntegrityError {
+	e := &IntegrityError{}
+	e.Problems = problems
+	return e
+}
+
+func (e *IntegrityError) Error() string {
+	return "the essence file failed the integrity checks: " + strings.Join(e.Problems, "; ")
+}
+
+// the base units every UCUM model must define
+var requiredBaseUnits = []string{"m", "s", "g", "rad", "K", "C", "cd"}
+
+//IntegrityChecker=====================================================
+/**
+IntegrityChecker checks the integrity of a loaded model:
+- the base units of UCUM are present
+- codes are unique, case sensitive, and case insensitive within units (except for aliases) and within prefixes
+- the definitions of defined units only refer to known units
+- a prefix followed by a metric atom does not result in the code of another atom
+ */
+type IntegrityChecker struct {
+	Model    *UcumModel
+	Problems []string
+}
+
+func NewIntegrityChecker(model *UcumModel) *IntegrityChecker {
+	c := &IntegrityChecker{}
+	c.Model = model
+	return c
+}
+
+func (c *IntegrityChecker) Check() []string {
+	c.Problems = make([]string, 0)
+	c.checkBaseUnits()
+	c.checkDuplicateCodes()
+	c.checkReferences()
+	c.checkPrefixCollisions()
+	return c.Problems
+}
+
+func (c *IntegrityChecker) checkBaseUnits() {
+	for _, code := range requiredBaseUnits {
+		if c.Model.BaseUnitsByCode[code] == nil {
+			c.Problems = append(c.Problems, "missing base unit "+code)
+		}
+	}
+}
+
+func (c *IntegrityChecker) checkDuplicateCodes() {
+	codes := make(map[string]bool)
+	codesUC := make(map[string]string)
+	check := func(concept Concepter) {
+		if codes[concept.GetCode()] {
+			c.Problems = append(c.Problems, "duplicate code "+concept.GetCode())
+		}
+		codes[concept.GetCode()] = true
+		if concept.GetCodeUC() == "" {
+			return
+		}
+		if code, found := codesUC[concept.GetCodeUC()]; found && !isAlias(concept, code) {
+			c.Problems = append(c.Problems, "duplicate case insensitive code "+concept.GetCodeUC())
+		}
+		codesUC[concept.GetCodeUC()] = concept.GetCode()
+	}
+	for _, u := range c.Model.BaseUnits {
+		check(u)
+	}
+	for _, u := range c.Model.DefinedUnits {
+		check(u)
+	}
+	//prefixes have codes of their own, "G" is both giga and gauss
+	codes = make(map[string]bool)
+	codesUC = make(map[string]string)
+	for _, p := range c.Model.Prefixes {
+		check(p)
+	}
+}
+
+// a unit defined as another unit may share its case insensitive code, as [IU] = [iU]
+func isAlias(concept Concepter, code string) bool {
+	unit, instanceof := concept.(*DefinedUnit)
+	return instanceof && unit.Value != nil && unit.Value.Unit == code
+}
+
+func (c *IntegrityChecker) checkReferences() {
+	for _, u := range c.Model.DefinedUnits {
+		if u.IsSpecial {
+			continue
+		}
+		if u.Value == nil || u.Value.Unit == "" {
+			c.Problems = append(c.Problems, "unit "+u.Code+" has no definition")
+			continue
+		}
+		if _, err := NewExpressionParser(c.Model).Parse(u.Value.Unit); err != nil {
+			c.Problems = append(c.Problems, "unit "+u.Code+" refers to an unknown unit: "+err.Error())
+		}
+	}
+}
+
+func (c *IntegrityChecker) checkPrefixCollisions() {
+	for _, p := range c.Model.Prefixes {
+		for _, u := range c.Model.BaseUnits {
+			c.checkPrefixCollision(p, u)
+		}
+		for _, u := range c.Model.DefinedUnits {
+			if u.Metric {
+				c.checkPrefixCollision(p, u)
+			}
+		}
+	}
+}
+
+func (c *IntegrityChecker) checkPrefixCollision(prefix *Prefix, unit Uniter) {
+	code := prefix.Code + unit.GetCode()
+	if c.Model.GetUnit(code) != nil {
+		c.Problems = append(c.Problems, "prefix "+prefix.Code+" with unit "+unit.GetCode()+" collides with unit "+code)
+	}
+}
@@ -0,0 +1,185 @@
+package ucum
+
+
+import (
+	"bytes"
+	"strconv"
+	"unicode/utf8"
+)
+
+/**
+UCUM codes are ASCII only, but units copied from documents often contain Unicode characters which
+look like the ASCII code, e.g. µg, °C, m², ×10⁹/L. LookalikeCode tells which kind of look-alike was found:
+- MICRO_SIGN: µ or μ, write u
+- SUPERSCRIPT: ⁰..⁹, ⁺ and ⁻, write the digits, after 10 as power of ten (10⁹ = 10*9)
+- DEGREE_SIGN: °C or ℃ write Cel, °F or ℉ write [degF], ° write deg
+- MULTIPLICATION_SIGN: ×, · or ⋅, write . (dropped at the start of the unit, ×10⁹/L = 10*9/L)
+- MINUS_SIGN: − or ‐, write -
+- OHM_SIGN: Ω, write Ohm
+- ANGSTROM_SIGN: Å, write Ao
+- PRIME_SIGN: ′ and ″, write ' and ''
+- NON_ASCII: any other non ASCII character, there is no replacement
+ */
+type LookalikeCode int
+
+const (
+	MICRO_SIGN LookalikeCode = iota
+	SUPERSCRIPT
+	DEGREE_SIGN
+	MULTIPLICATION_SIGN
+	MINUS_SIGN
+	OHM_SIGN
+	ANGSTROM_SIGN
+	PRIME_SIGN
+	NON_ASCII
+)
+
+// a look-alike found in a unit, Position is the byte position of Text in the unit
+type Lookalike struct {
+	Code        LookalikeCode
+	Position    int
+	Text        string
+	Replacement string
+}
+
+// describes the look-alike and its replacement, e.g. "'µ' at position 0, write 'u' instead"
+func (l *Lookalike) String() string {
+	result := "'" + l.Text + "' at position " + strconv.Itoa(l.Position)
+	switch {
+	case l.Code == NON_ASCII:
+		return result + " is not an ASCII character"
+	case l.Replacement == "":
+		return result + ", remove it"
+	}
+	return result + ", write '" + l.Replacement + "' instead"
+}
+
+var singleLookalikes = map[rune]*Lookalike{
+	'µ': {Code: MICRO_SIGN, Replacement: "u"},
+	'μ': {Code: MICRO_SIGN, Replacement: "u"},
+	'℃': {Code: DEGREE_SIGN, Replacement: "Cel"},
+	'℉': {Code: DEGREE_SIGN, Replacement: "[degF]"},
+	'×': {Code: MULTIPLICATION_SIGN, Replacement: "."},
+	'·': {Code: MULTIPLICATION_SIGN, Replacement: "."},
+	'⋅': {Code: MULTIPLICATION_SIGN, Replacement: "."},
+	'∙': {Code: MULTIPLICATION_SIGN, Replacement: "."},
+	'−': {Code: MINUS_SIGN, Replacement: "-"},
+	'‐': {Code: MINUS_SIGN, Replacement: "-"},
+	'–': {Code: MINUS_SIGN, Replacement: "-"},
+	'\u03A9': {Code: OHM_SIGN, Replacement: "Ohm"},
+	'\u2126': {Code: OHM_SIGN, Replacement: "Ohm"},
+	'\u00C5': {Code: ANGSTROM_SIGN, Replacement: "Ao"},
+	'\u212B': {Code: ANGSTROM_SIGN, Replacement: "Ao"},
+	'′': {Code: PRIME_SIGN, Replacement: "'"},
+	'″': {Code: PRIME_SIGN, Replacement: "''"},
+}
+
+var superscriptDigits = map[rune]byte{
+	'⁰': '0', '¹': '1', '²': '2', '³': '3', '⁴': '4', '⁵': '5', '⁶': '6', '⁷': '7', '⁸': '8', '⁹': '9',
+	'⁺': '+', '⁻': '-',
+}
+
+/**
+FindLookalike returns the look-alike starting at byte position index of unit, which must be the start
+of a non ASCII character. A run of superscripts, and a degree sign with its letter, are one look-alike.
+ */
+func FindLookalike(unit string, index int) *Lookalike {
+	r, size := utf8.DecodeRuneInString(unit[index:])
+	if single, found := singleLookalikes[r]; found {
+		result := &Lookalike{Code: single.Code, Position: index, Text: unit[index : index+size], Replacement: single.Replacement}
+		//a multiplication sign in front, as in ×10⁹/L, belongs to the number before the unit
+		if result.Code == MULTIPLICATION_SIGN && index == 0 {
+			result.Replacement = ""
+		}
+		return result
+	}
+	if r == '°' {
+		result := &Lookalike{Code: DEGREE_SIGN, Position: index, Text: "°", Replacement: "deg"}
+		if next := index + size; next < len(unit) {
+			switch unit[next] {
+			case 'C':
+				result.Text, result.Replacement = "°C", "Cel"
+			case 'F':
+				result.Text, result.Replacement = "°F", "[degF]"
+			}
+		}
+		return result
+	}
+	if _, found := superscriptDigits[r]; found {
+		var replacement bytes.Buffer
+		end := index
+		for end < len(unit) {
+			r, size = utf8.DecodeRuneInString(unit[end:])
+			digit, found := superscriptDigits[r]
+			if !found {
+				break
+			}
+			replacement.WriteByte(digit)
+			end += size
+		}
+		result := &Lookalike{Code: SUPERSCRIPT, Position: index, Text: unit[index:end], Replacement: replacement.String()}
+		if isPowerOfTen(unit, index) {
+			result.Replacement = "*" + result.Replacement
+		}
+		return result
+	}
+	return &Lookalike{Code: NON_ASCII, Position: index, Text: unit[index : index+size]}
+}
+
+// a superscript at index is the exponent of a power of ten if it follows a number 10
+func isPowerOfTen(unit string, index int) bool {
+	if index < 2 || unit[index-2:index] != "10" {
+		return false
+	}
+	if index == 2 {
+		return true
+	}
+	before := unit[index-3]
+	return !(before >= '0' && before <= '9') && !(before >= 'a' && before <= 'z') && !(before >= 'A' && before <= 'Z') &&
+		before != ']' && before != '_'
+}
+
+/**
+ReplaceLookalikes replaces the look-alikes in unit by their ASCII codes, and reports the replacements.
+Returns a UnicodeError for a non ASCII character without replacement.
+ */
+func ReplaceLookalikes(unit string) (string, []*Lookalike, error) {
+	var buffer bytes.Buffer
+	replacements := make([]*Lookalike, 0)
+	for i := 0; i < len(unit); {
+		if unit[i] < utf8.RuneSelf {
+			buffer.WriteByte(unit[i])
+			i++
+			continue
+		}
+		lookalike := FindLookalike(unit, i)
+		if lookalike.Code == NON_ASCII {
+			return "", nil, NewUnicodeError(unit, lookalike)
+		}
+		buffer.WriteString(lookalike.Replacement)
+		replacements = append(replacements, lookalike)
+		i += len(lookalike.Text)
+	}
+	return buffer.String(), replacements, nil
+}
+
+// UnicodeError=======================================================
+/**
+Returned by the lexer for a non ASCII character in a unit, Lookalike tells which character was found,
+and what to write instead.
+ */
+type UnicodeError struct {
+	Source    string
+	Lookalike *Lookalike
+}
+
+func NewUnicodeError(source string, lookalike *Lookalike) *UnicodeError {
+	e := &UnicodeError{}
+	e.Source = source
+	e.Lookalike = lookalike
+	return e
+}
+
+func (e *UnicodeError) Error() string {
+	return "Error processing unit '" + e.Source + "': UCUM codes are ASCII only (" + e.Lookalike.Code.String() + "): " + e.Lookalike.String()
+}
@@ -0,0 +1,197 @@
+package ucum
+
+
+import (
+	"bytes"
+	"encoding/xml"
+	"html"
+	"strings"
+	"unicode"
+)
+
+/**
+Markup is text with the markup of the essence file, as in print symbols and value texts:
+<sup> (superscript), <sub> (subscript), <i> (italic) and <r> (roman).
+Raw is the markup as found in the essence file, it is rendered by Plain, Unicode, HTML and LaTeX.
+ */
+type Markup struct {
+	Raw string
+}
+
+func NewMarkup(raw string) *Markup {
+	m := &Markup{}
+	m.Raw = raw
+	return m
+}
+
+// renders without markup, superscripts are written as ^x, subscripts as _x, e.g. "a_t", "10^-12"
+func (m *Markup) Plain() string {
+	return m.render(func(element, text string) string {
+		switch element {
+		case "sup":
+			return "^" + text
+		case "sub":
+			return "_" + text
+		}
+		return text
+	})
+}
+
+/**
+renders with Unicode superscript and subscript characters, e.g. "10⁻¹²", "aₜ".
+A superscript or subscript which has no Unicode characters is written as in Plain.
+ */
+func (m *Markup) Unicode() string {
+	return m.render(func(element, text string) string {
+		switch element {
+		case "sup":
+			if s, ok := ToSuperscript(text); ok {
+				return s
+			}
+			return "^" + text
+		case "sub":
+			if s, ok := ToSubscript(text); ok {
+				return s
+			}
+			return "_" + text
+		}
+		return text
+	})
+}
+
+// renders as HTML, e.g. "10<sup>-12</sup>", "<i>ε<sub>0</sub></i>"
+func (m *Markup) HTML() string {
+	return m.render(func(element, text string) string {
+		switch element {
+		case "sup", "sub", "i":
+			return "<" + element + ">" + text + "</" + element + ">"
+		case "":
+			return html.EscapeString(text)
+		}
+		return text
+	})
+}
+
+// renders as LaTeX for math mode, e.g. "10^{-12}", "\mathit{\varepsilon_{0}}", see EscapeLaTeX
+func (m *Markup) LaTeX() string {
+	return m.render(func(element, text string) string {
+		switch element {
+		case "sup":
+			return "^{" + text + "}"
+		case "sub":
+			return "_{" + text + "}"
+		case "i":
+			return `\mathit{` + text + "}"
+		case "":
+			return EscapeLaTeX(text)
+		}
+		return text
+	})
+}
+
+/**
+walks through the markup, render is called with the element name and the rendered content
+of each element, and with element "" for text. Whitespace is collapsed; whitespace containing
+a line break at the start or end of a text is layout of the essence file and is dropped.
+ */
+func (m *Markup) render(render func(element, text string) string) string {
+	decoder := xml.NewDecoder(strings.NewReader("<m>" + m.Raw + "</m>"))
+	var renderElement func() string
+	renderElement = func() string {
+		var buffer bytes.Buffer
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				break
+			}
+			switch t := token.(type) {
+			case xml.StartElement:
+				buffer.WriteString(render(t.Name.Local, renderElement()))
+			case xml.EndElement:
+				return buffer.String()
+			case xml.CharData:
+				buffer.WriteString(render("", collapseLayout(string(t))))
+			}
+		}
+		return buffer.String()
+	}
+	//skip <m>
+	if _, err := decoder.Token(); err != nil {
+		return m.Raw
+	}
+	return strings.TrimSpace(renderElement())
+}
+
+func collapseLayout(text string) string {
+	start := strings.IndexFunc(text, func(r rune) bool { return !unicode.IsSpace(r) })
+	if start < 0 {
+		if strings.Contains(text, "\n") {
+			return ""
+		}
+		return " "
+	}
+	end := len(strings.TrimRightFunc(text, unicode.IsSpace))
+	result := strings.Join(strings.Fields(text[start:end]), " ")
+	if start > 0 && !strings.Contains(text[:start], "\n") {
+		result = " " + result
+	}
+	if end < len(text) && !strings.Contains(text[end:], "\n") {
+		result = result + " "
+	}
+	return result
+}
+
+// the LaTeX commands for the special characters of LaTeX, and for the non ASCII characters of print symbols
+var latexCharacters = map[rune]string{
+	'\\': `\backslash{}`, '{': `\{`, '}': `\}`, '%': `\%`, '$': `\$`, '#': `\#`, '&': `\&`, '_': `\_`,
+	'^': `\hat{}`, '~': `\sim{}`, ' ': `\ `,
+	'μ': `\mu{}`, 'π': `\pi{}`, 'Ω': `\Omega{}`, 'ε': `\varepsilon{}`, 'γ': `\gamma{}`, 'σ': `\sigma{}`,
+	'°': `^{\circ}`, '′': `'`, '″': `''`, 'Å': `\AA{}`,
+}
+
+// escapes text for LaTeX math mode, the characters of latexCharacters are replaced by their command
+func EscapeLaTeX(text string) string {
+	var buffer bytes.Buffer
+	for _, r := range text {
+		if command, found := latexCharacters[r]; found {
+			buffer.WriteString(command)
+		} else {
+			buffer.WriteRune(r)
+		}
+	}
+	return buffer.String()
+}
+
+var superscripts = map[rune]rune{
+	'0': '⁰', '1': '¹', '2': '²', '3': '³', '4': '⁴', '5': '⁵', '6': '⁶', '7': '⁷', '8': '⁸', '9': '⁹',
+	'+': '⁺', '-': '⁻', '=': '⁼', '(': '⁽', ')': '⁾', 'n': 'ⁿ', 'i': 'ⁱ',
+}
+
+var subscripts = map[rune]rune{
+	'0': '₀', '1': '₁', '2': '₂', '3': '₃', '4': '₄', '5': '₅', '6': '₆', '7': '₇', '8': '₈', '9': '₉',
+	'+': '₊', '-': '₋', '=': '₌', '(': '₍', ')': '₎',
+	'a': 'ₐ', 'e': 'ₑ', 'h': 'ₕ', 'i': 'ᵢ', 'j': 'ⱼ', 'k': 'ₖ', 'l': 'ₗ', 'm': 'ₘ', 'n': 'ₙ',
+	'o': 'ₒ', 'p': 'ₚ', 'r': 'ᵣ', 's': 'ₛ', 't': 'ₜ', 'u': 'ᵤ', 'v': 'ᵥ', 'x': 'ₓ',
+}
+
+// returns the text in Unicode superscript characters, false if a character has none
+func ToSuperscript(text string) (string, bool) {
+	return mapRunes(text, superscripts)
+}
+
+// returns the text in Unicode subscript characters, false if a character has none
+func ToSubscript(text string) (string, bool) {
+	return mapRunes(text, subscripts)
+}
+
+func mapRunes(text string, runes map[rune]rune) (string, bool) {
+	var buffer bytes.Buffer
+	for _, r := range text {
+		m, ok := runes[r]
+		if !ok {
+			return text, false
+		}
+		buffer.WriteRune(m)
+	}
+	return buffer.String(), true
+}
@@ -0,0 +1,253 @@
+package ucum
+
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/**
+ConceptChange is a change of a field of a concept present in both models.
+Field is one of: name, printSymbol, value, definition, property, class, special, metric, arbitrary, dim, canonical
+ */
+type ConceptChange struct {
+	Kind  ConceptKind
+	Code  string
+	Field string
+	Old   string
+	New   string
+}
+
+// a concept which got another code, recognised by having the same kind and name
+type ConceptRename struct {
+	Kind    ConceptKind
+	OldCode string
+	NewCode string
+}
+
+/**
+ModelDiff holds the differences between two versions of the UCUM model, see DiffModels.
+Added, Removed and Renamed are sorted on kind and code, Changed on kind, code and field.
+ */
+type ModelDiff struct {
+	OldVersion string
+	NewVersion string
+	Added      []Concepter
+	Removed    []Concepter
+	Renamed    []*ConceptRename
+	Changed    []*ConceptChange
+}
+
+func (d *ModelDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Renamed) == 0 && len(d.Changed) == 0
+}
+
+// returns the changes of the concept with the code
+func (d *ModelDiff) GetChanges(kind ConceptKind, code string) []*ConceptChange {
+	result := make([]*ConceptChange, 0)
+	for _, c := range d.Changed {
+		if c.Kind == kind && c.Code == code {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// a readable report, one line per difference
+func (d *ModelDiff) String() string {
+	var buffer bytes.Buffer
+	buffer.WriteString("UCUM " + d.OldVersion + " -> " + d.NewVersion + "\n")
+	for _, c := range d.Added {
+		buffer.WriteString("+ " + strings.ToLower(c.GetKind().String()) + " " + c.GetCode() + "\n")
+	}
+	for _, c := range d.Removed {
+		buffer.WriteString("- " + strings.ToLower(c.GetKind().String()) + " " + c.GetCode() + "\n")
+	}
+	for _, r := range d.Renamed {
+		buffer.WriteString("> " + strings.ToLower(r.Kind.String()) + " " + r.OldCode + " renamed to " + r.NewCode + "\n")
+	}
+	for _, c := range d.Changed {
+		buffer.WriteString("~ " + strings.ToLower(c.Kind.String()) + " " + c.Code + " " + c.Field + ": " +
+			strconv.Quote(c.Old) + " -> " + strconv.Quote(c.New) + "\n")
+	}
+	return buffer.String()
+}
+
+//ModelDiffer=====================================================
+type modelDiffer struct {
+	oldModel     *UcumModel
+	newModel     *UcumModel
+	oldConverter *Converter
+	newConverter *Converter
+	diff         *ModelDiff
+}
+
+/**
+DiffModels reports the prefixes, base units and defined units added, removed or renamed in newModel,
+and the changes of the concepts in both models, including the units of which the canonical form
+changed (by changes of the unit itself, or of the units it is defined with).
+handlers are used for the canonical forms, a new Registry if nil.
+ */
+func DiffModels(oldModel, newModel *UcumModel, handlers *Registry) *ModelDiff {
+	d := &modelDiffer{}
+	d.oldModel = oldModel
+	d.newModel = newModel
+	d.oldConverter = NewConverter(oldModel, handlers)
+	d.newConverter = NewConverter(newModel, handlers)
+	d.diff = &ModelDiff{}
+	d.diff.OldVersion = oldModel.Version
+	d.diff.NewVersion = newModel.Version
+	d.diff.Added = make([]Concepter, 0)
+	d.diff.Removed = make([]Concepter, 0)
+	d.diff.Renamed = make([]*ConceptRename, 0)
+	d.diff.Changed = make([]*ConceptChange, 0)
+	oldPrefixes, newPrefixes := make([]Concepter, 0), make([]Concepter, 0)
+	for _, p := range oldModel.Prefixes {
+		oldPrefixes = append(oldPrefixes, p)
+	}
+	for _, p := range newModel.Prefixes {
+		newPrefixes = append(newPrefixes, p)
+	}
+	d.diffConcepts(oldPrefixes, newPrefixes)
+	oldBaseUnits, newBaseUnits := make([]Concepter, 0), make([]Concepter, 0)
+	for _, b := range oldModel.BaseUnits {
+		oldBaseUnits = append(oldBaseUnits, b)
+	}
+	for _, b := range newModel.BaseUnits {
+		newBaseUnits = append(newBaseUnits, b)
+	}
+	d.diffConcepts(oldBaseUnits, newBaseUnits)
+	oldUnits, newUnits := make([]Concepter, 0), make([]Concepter, 0)
+	for _, u := range oldModel.DefinedUnits {
+		oldUnits = append(oldUnits, u)
+	}
+	for _, u := range newModel.DefinedUnits {
+		newUnits = append(newUnits, u)
+	}
+	d.diffConcepts(oldUnits, newUnits)
+	d.sort()
+	return d.diff
+}
+
+func (d *modelDiffer) diffConcepts(oldConcepts, newConcepts []Concepter) {
+	oldByCode := make(map[string]Concepter)
+	for _, c := range oldConcepts {
+		oldByCode[c.GetCode()] = c
+	}
+	newByCode := make(map[string]Concepter)
+	for _, c := range newConcepts {
+		newByCode[c.GetCode()] = c
+	}
+	removed := make([]Concepter, 0)
+	for _, c := range oldConcepts {
+		if n, found := newByCode[c.GetCode()]; found {
+			d.diffConcept(c, n)
+		} else {
+			removed = append(removed, c)
+		}
+	}
+	added := make([]Concepter, 0)
+	for _, c := range newConcepts {
+		if _, found := oldByCode[c.GetCode()]; !found {
+			added = append(added, c)
+		}
+	}
+	//a removed and an added concept with the same name is a renamed concept
+	for _, r := range removed {
+		renamed := false
+		for i, a := range added {
+			if a != nil && firstName(a) != "" && strings.EqualFold(firstName(a), firstName(r)) {
+				d.diff.Renamed = append(d.diff.Renamed, &ConceptRename{Kind: r.GetKind(), OldCode: r.GetCode(), NewCode: a.GetCode()})
+				d.diffConcept(r, a)
+				added[i] = nil
+				renamed = true
+				break
+			}
+		}
+		if !renamed {
+			d.diff.Removed = append(d.diff.Removed, r)
+		}
+	}
+	for _, a := range added {
+		if a != nil {
+			d.diff.Added = append(d.diff.Added, a)
+		}
+	}
+}
+
+func firstName(c Concepter) string {
+	if len(c.GetNames()) == 0 {
+		return ""
+	}
+	return c.GetNames()[0]
+}
+
+// compares the fields of two versions of a concept, the change is reported on the new code
+func (d *modelDiffer) diffConcept(oldConcept, newConcept Concepter) {
+	change := func(field, oldValue, newValue string) {
+		if oldValue != newValue {
+			d.diff.Changed = append(d.diff.Changed, &ConceptChange{Kind: newConcept.GetKind(), Code: newConcept.GetCode(),
+				Field: field, Old: oldValue, New: newValue})
+		}
+	}
+	change("name", strings.Join(oldConcept.GetNames(), ", "), strings.Join(newConcept.GetNames(), ", "))
+	change("printSymbol", oldConcept.GetPrintSymbol(), newConcept.GetPrintSymbol())
+	switch o := oldConcept.(type) {
+	case *Prefix:
+		change("value", o.Value.String(), newConcept.(*Prefix).Value.String())
+	case *BaseUnit:
+		n := newConcept.(*BaseUnit)
+		change("property", o.Property, n.Property)
+		change("dim", string(o.Dim), string(n.Dim))
+	case *DefinedUnit:
+		n := newConcept.(*DefinedUnit)
+		change("definition", o.Value.Unit, n.Value.Unit)
+		change("value", o.Value.Value.String(), n.Value.Value.String())
+		change("property", o.Property, n.Property)
+		change("class", o.Class, n.Class)
+		change("special", strconv.FormatBool(o.IsSpecial), strconv.FormatBool(n.IsSpecial))
+		change("metric", strconv.FormatBool(o.Metric), strconv.FormatBool(n.Metric))
+		change("arbitrary", strconv.FormatBool(o.IsArbitrary), strconv.FormatBool(n.IsArbitrary))
+		change("canonical", d.canonical(d.oldModel, d.oldConverter, o.Code), d.canonical(d.newModel, d.newConverter, n.Code))
+	}
+}
+
+// returns the canonical form of the unit as value and units, or the error
+func (d *modelDiffer) canonical(model *UcumModel, converter *Converter, code string) string {
+	c, err := canonicalOf(model, converter, code)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return c.Value.String() + " " + ComposeExpression(c, false)
+}
+
+func (d *modelDiffer) sort() {
+	conceptLess := func(list []Concepter) func(i, j int) bool {
+		return func(i, j int) bool {
+			if list[i].GetKind() != list[j].GetKind() {
+				return list[i].GetKind() < list[j].GetKind()
+			}
+			return list[i].GetCode() < list[j].GetCode()
+		}
+	}
+	sort.SliceStable(d.diff.Added, conceptLess(d.diff.Added))
+	sort.SliceStable(d.diff.Removed, conceptLess(d.diff.Removed))
+	sort.SliceStable(d.diff.Renamed, func(i, j int) bool {
+		if d.diff.Renamed[i].Kind != d.diff.Renamed[j].Kind {
+			return d.diff.Renamed[i].Kind < d.diff.Renamed[j].Kind
+		}
+		return d.diff.Renamed[i].OldCode < d.diff.Renamed[j].OldCode
+	})
+	sort.SliceStable(d.diff.Changed, func(i, j int) bool {
+		a, b := d.diff.Changed[i], d.diff.Changed[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Code != b.Code {
+			return a.Code < b.Code
+		}
+		return a.Field < b.Field
+	})
+}
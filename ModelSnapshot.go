@@ -0,0 +1,312 @@
+package ucum
+
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bertverhees/ucum/decimal"
+)
+
+/**
+A snapshot is a UcumModel serialized in JSON or binary (gob) form, including the canonical forms
+of the defined units and the search index, so it is ready to use without parsing the essence file.
+The snapshot carries a checksum of the model data, which is verified when reading it.
+SNAPSHOT_FORMAT is raised when the layout of the snapshot changes, older snapshots are refused.
+ */
+const SNAPSHOT_FORMAT = 1
+
+type snapshotEnvelope struct {
+	Format   int             `json:"format"`
+	Checksum string          `json:"checksum"`
+	Model    json.RawMessage `json:"model"`
+}
+
+type binarySnapshotEnvelope struct {
+	Format   int
+	Checksum string
+	Model    []byte
+}
+
+type modelSnapshot struct {
+	Version             string
+	Revision            string
+	RevisionNumber      int
+	RevisionDate        time.Time
+	Prefixes            []*Prefix
+	BaseUnits           []*BaseUnit
+	DefinedUnits        []*DefinedUnit
+	ClassInfos          []*UcumClassInfo
+	PropertyList        []string
+	ClassList           []string
+	PropertySearchIndex map[string][]string
+	ClassSearchIndex    map[string][]string
+	Canonicals          map[string]*canonicalSnapshot
+	Handlers            []handlerSnapshot
+	SearchIndex         *searchIndexSnapshot
+}
+
+// a special unit handler the canonicals were computed with
+type handlerSnapshot struct {
+	Code  string
+	Units string
+	Value decimal.Decimal
+}
+
+type canonicalSnapshot struct {
+	Value          decimal.Decimal
+	Units          []canonicalUnitSnapshot
+	ArbitraryUnits map[string]int
+	Entities       map[string]int
+	Dimensionless  bool
+	RatioKinds     []string
+}
+
+type canonicalUnitSnapshot struct {
+	Base     string
+	Exponent int
+}
+
+type searchIndexSnapshot struct {
+	Concepts []conceptReference
+	Exact    map[string][]int
+	Codes    map[string][]int
+	Tokens   map[string][]int
+	Words    []string
+	Names    [][]string
+}
+
+type conceptReference struct {
+	Kind ConceptKind
+	Code string
+}
+
+// writes the model as JSON snapshot, the canonicals are computed with handlers (a new Registry if nil)
+func WriteModelJSON(model *UcumModel, handlers *Registry, writer io.Writer) error {
+	data, err := json.Marshal(newModelSnapshot(model, handlers))
+	if err != nil {
+		return err
+	}
+	envelope := &snapshotEnvelope{Format: SNAPSHOT_FORMAT, Checksum: checksum(data), Model: data}
+	return json.NewEncoder(writer).Encode(envelope)
+}
+
+// writes the model as binary snapshot, the canonicals are computed with handlers (a new Registry if nil)
+func WriteModelBinary(model *UcumModel, handlers *Registry, writer io.Writer) error {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(newModelSnapshot(model, handlers)); err != nil {
+		return err
+	}
+	envelope := &binarySnapshotEnvelope{Format: SNAPSHOT_FORMAT, Checksum: checksum(buffer.Bytes()), Model: buffer.Bytes()}
+	return gob.NewEncoder(writer).Encode(envelope)
+}
+
+/**
+reads a JSON snapshot written by WriteModelJSON.
+Returns an error if the format or the checksum is wrong, or if expectedVersion is not empty and
+differs from the UCUM version of the model.
+ */
+func ReadModelJSON(reader io.Reader, expectedVersion string) (*UcumModel, error) {
+	envelope := &snapshotEnvelope{}
+	if err := json.NewDecoder(reader).Decode(envelope); err != nil {
+		return nil, err
+	}
+	if err := verifySnapshot(envelope.Format, envelope.Checksum, envelope.Model); err != nil {
+		return nil, err
+	}
+	snapshot := &modelSnapshot{}
+	if err := json.Unmarshal(envelope.Model, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot.restore(expectedVersion)
+}
+
+// reads a binary snapshot written by WriteModelBinary, see ReadModelJSON
+func ReadModelBinary(reader io.Reader, expectedVersion string) (*UcumModel, error) {
+	envelope := &binarySnapshotEnvelope{}
+	if err := gob.NewDecoder(reader).Decode(envelope); err != nil {
+		return nil, err
+	}
+	if err := verifySnapshot(envelope.Format, envelope.Checksum, envelope.Model); err != nil {
+		return nil, err
+	}
+	snapshot := &modelSnapshot{}
+	if err := gob.NewDecoder(bytes.NewReader(envelope.Model)).Decode(snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot.restore(expectedVersion)
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func verifySnapshot(format int, sum string, data []byte) error {
+	if format != SNAPSHOT_FORMAT {
+		return fmt.Errorf("snapshot has format " + strconv.Itoa(format) + ", expected " + strconv.Itoa(SNAPSHOT_FORMAT))
+	}
+	if checksum(data) != sum {
+		return fmt.Errorf("snapshot checksum does not match, the snapshot is corrupted")
+	}
+	return nil
+}
+
+func newModelSnapshot(model *UcumModel, handlers *Registry) *modelSnapshot {
+	if handlers == nil {
+		handlers = NewRegistry()
+	}
+	s := &modelSnapshot{}
+	s.Version = model.Version
+	s.Revision = model.Revision
+	s.RevisionNumber = model.RevisionNumber
+	s.RevisionDate = model.RevisionDate
+	s.Prefixes = model.Prefixes
+	s.BaseUnits = model.BaseUnits
+	s.DefinedUnits = model.DefinedUnits
+	s.ClassInfos = make([]*UcumClassInfo, 0)
+	for _, class := range model.ClassList {
+		if info := model.UcumClassInfoMap[class]; info != nil {
+			s.ClassInfos = append(s.ClassInfos, info)
+		}
+	}
+	for name, info := range model.UcumClassInfoMap {
+		if !containsString(model.ClassList, name) {
+			s.ClassInfos = append(s.ClassInfos, info)
+		}
+	}
+	s.PropertyList = model.PropertyList
+	s.ClassList = model.ClassList
+	s.PropertySearchIndex = model.PropertySearchIndex
+	s.ClassSearchIndex = model.ClassSearchIndex
+	s.Canonicals = make(map[string]*canonicalSnapshot)
+	for code, can := range model.computeCanonicals(handlers) {
+		cs := &canonicalSnapshot{Value: can.Value, ArbitraryUnits: can.ArbitraryUnits, Entities: can.entities,
+			Dimensionless: can.Dimensionless, RatioKinds: can.RatioKinds}
+		for _, cu := range can.Units {
+			cs.Units = append(cs.Units, canonicalUnitSnapshot{Base: cu.Base.Code, Exponent: cu.Exponent})
+		}
+		s.Canonicals[code] = cs
+	}
+	for code, handler := range handlers.handlers {
+		s.Handlers = append(s.Handlers, handlerSnapshot{Code: code, Units: handler.GetUnits(), Value: handler.GetValue()})
+	}
+	sort.Slice(s.Handlers, func(i, j int) bool {
+		return s.Handlers[i].Code < s.Handlers[j].Code
+	})
+	index := model.SearchIndex
+	if index == nil {
+		index = NewSearchIndex(model)
+	}
+	s.SearchIndex = &searchIndexSnapshot{Exact: index.exact, Codes: index.codes, Tokens: index.tokens,
+		Words: index.words, Names: index.names}
+	for _, c := range index.concepts {
+		s.SearchIndex.Concepts = append(s.SearchIndex.Concepts, conceptReference{Kind: c.GetKind(), Code: c.GetCode()})
+	}
+	return s
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// rebuilds the model, with the same pointers between concepts as a parsed model
+func (s *modelSnapshot) restore(expectedVersion string) (*UcumModel, error) {
+	if expectedVersion != "" && s.Version != expectedVersion {
+		return nil, fmt.Errorf("snapshot has UCUM version " + s.Version + ", expected " + expectedVersion)
+	}
+	model := NewUcumModel(s.Version, s.Revision, s.RevisionDate)
+	model.RevisionNumber = s.RevisionNumber
+	model.BaseUnitsByCodeUC = nil
+	model.DefinedUnitsByCodeUC = nil
+	model.Prefixes = s.Prefixes
+	model.BaseUnits = s.BaseUnits
+	model.DefinedUnits = s.DefinedUnits
+	for _, b := range model.BaseUnits {
+		model.BaseUnitsByCode[b.Code] = b
+	}
+	for _, d := range model.DefinedUnits {
+		model.DefinedUnitsByCode[d.Code] = d
+	}
+	model.UcumClassInfoMap = make(map[string]*UcumClassInfo)
+	for _, info := range s.ClassInfos {
+		model.UcumClassInfoMap[info.Name] = info
+	}
+	model.PropertyList = s.PropertyList
+	model.ClassList = s.ClassList
+	model.PropertySearchIndex = s.PropertySearchIndex
+	model.ClassSearchIndex = s.ClassSearchIndex
+	model.canonicals = make(map[string]*Canonical)
+	model.canonicalHandlers = &Registry{handlers: make(map[string]SpecialUnitHandlerer)}
+	for _, hs := range s.Handlers {
+		model.canonicalHandlers.register(NewHoldingHandler(hs.Code, hs.Units, hs.Value))
+	}
+	for code, cs := range s.Canonicals {
+		can, _ := NewCanonical(cs.Value)
+		for _, cus := range cs.Units {
+			base := model.BaseUnitsByCode[cus.Base]
+			if base == nil {
+				return nil, fmt.Errorf("snapshot refers to unknown base unit " + cus.Base)
+			}
+			cu, _ := NewCanonicalUnit(base, cus.Exponent)
+			can.Units = append(can.Units, cu)
+		}
+		can.AddArbitraryUnits(cs.ArbitraryUnits, 1)
+		can.addEntities(cs.Entities, 1)
+		can.Dimensionless = cs.Dimensionless
+		can.RatioKinds = append(can.RatioKinds, cs.RatioKinds...)
+		model.canonicals[code] = can
+	}
+	model.naturalParser = NewNaturalLanguageParser(model)
+	if s.SearchIndex == nil {
+		model.SearchIndex = NewSearchIndex(model)
+		return model, nil
+	}
+	prefixes := make(map[string]*Prefix)
+	for _, p := range model.Prefixes {
+		prefixes[p.Code] = p
+	}
+	index := &SearchIndex{model: model, exact: s.SearchIndex.Exact, codes: s.SearchIndex.Codes,
+		tokens: s.SearchIndex.Tokens, words: s.SearchIndex.Words, names: s.SearchIndex.Names}
+	for _, ref := range s.SearchIndex.Concepts {
+		var concept Concepter
+		switch ref.Kind {
+		case PREFIX:
+			if p := prefixes[ref.Code]; p != nil {
+				concept = p
+			}
+		case BASEUNIT:
+			if b := model.BaseUnitsByCode[ref.Code]; b != nil {
+				concept = b
+			}
+		default:
+			if d := model.DefinedUnitsByCode[ref.Code]; d != nil {
+				concept = d
+			}
+		}
+		if concept == nil {
+			return nil, fmt.Errorf("snapshot search index refers to unknown concept " + ref.Code)
+		}
+		index.concepts = append(index.concepts, concept)
+	}
+	if len(index.names) != len(index.concepts) {
+		return nil, fmt.Errorf("snapshot search index has names for " + strconv.Itoa(len(index.names)) +
+			" concepts, expected " + strconv.Itoa(len(index.concepts)))
+	}
+	index.derive()
+	model.SearchIndex = index
+	return model, nil
+}
@@ -0,0 +1,51 @@
+package ucum
+
+
+import (
+	"strings"
+	"github.com/bertverhees/ucum/decimal"
+)
+
+/**
+MolarMassTable holds the molar mass (in g/mol) of named analytes.
+It is consulted to convert between mass and amount of substance of the same analyte,
+e.g. glucose in mg/dL and mmol/L.
+Analyte names are case insensitive.
+ */
+type MolarMassTable struct {
+	molarMasses map[string]decimal.Decimal
+}
+
+func NewMolarMassTable() *MolarMassTable {
+	t := &MolarMassTable{}
+	t.molarMasses = make(map[string]decimal.Decimal)
+	t.Register("glucose", decimal.RequireFromString("180.156"))
+	t.Register("cholesterol", decimal.RequireFromString("386.65"))
+	t.Register("triglycerides", decimal.RequireFromString("885.7"))
+	t.Register("creatinine", decimal.RequireFromString("113.12"))
+	t.Register("urea", decimal.RequireFromString("60.06"))
+	t.Register("uric acid", decimal.RequireFromString("168.11"))
+	t.Register("bilirubin", decimal.RequireFromString("584.66"))
+	t.Register("calcium", decimal.RequireFromString("40.078"))
+	t.Register("magnesium", decimal.RequireFromString("24.305"))
+	t.Register("phosphate", decimal.RequireFromString("30.974"))
+	t.Register("iron", decimal.RequireFromString("55.845"))
+	t.Register("sodium", decimal.RequireFromString("22.990"))
+	t.Register("potassium", decimal.RequireFromString("39.098"))
+	t.Register("chloride", decimal.RequireFromString("35.453"))
+	t.Register("vitamin d", decimal.RequireFromString("384.64"))
+	return t
+}
+
+func (t *MolarMassTable) Register(analyte string, molarMass decimal.Decimal) {
+	t.molarMasses[strings.ToLower(analyte)] = molarMass
+}
+
+func (t *MolarMassTable) Exists(analyte string) bool {
+	_, ok := t.molarMasses[strings.ToLower(analyte)]
+	return ok
+}
+
+func (t *MolarMassTable) Get(analyte string) decimal.Decimal {
+	return t.molarMasses[strings.ToLower(analyte)]
+}
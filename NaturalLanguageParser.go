@@ -0,0 +1,261 @@
+package ucum
+
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/**
+NaturalLanguageParser parses an English phrase into a UCUM expression, the inverse of NaturalLanguageRenderer,
+e.g. "milligrams per deciliter" -> "mg/dL", "micrograms per kilogram per minute" -> "ug/kg/min",
+"square meters" -> "m2", "degrees Celsius" -> "Cel", "beats per minute" -> "{beats}/min".
+The grammar is:
+	phrase = [group] {"per" group}
+	group  = item {item}
+	item   = number | "ten to the power" n | ["square" | "cubic"] unit ["squared" | "cubed" | "to the power" ["of"] n]
+A unit is named by a name of the model (singular or plural, case insensitive), optionally preceded by
+the name of a prefix for metric units, as "milli" in "milligrams". A count noun (see countNouns) in place
+of a unit becomes an annotation, "beats per minute" -> "{beats}/min", any other unknown word is an error.
+ */
+type NaturalLanguageParser struct {
+	Model    *UcumModel
+	units    map[string]Uniter
+	prefixes []*Prefix
+	maxWords int
+}
+
+// the unit of a name used by more than one unit of the bundled English translations
+var preferredNames = map[string]string{
+	"liter":              "L",
+	"international unit": "[iU]",
+}
+
+// the things counted, in singular and plural, which are written as annotations
+var countNouns = map[string]bool{
+	"beat": true, "beats": true, "breath": true, "breaths": true, "cell": true, "cells": true,
+	"drop": true, "drops": true, "dose": true, "doses": true, "tablet": true, "tablets": true,
+	"capsule": true, "capsules": true, "puff": true, "puffs": true, "copy": true, "copies": true,
+	"colony": true, "colonies": true, "particle": true, "particles": true, "event": true, "events": true,
+	"episode": true, "episodes": true, "step": true, "steps": true, "count": true, "counts": true,
+}
+
+func NewNaturalLanguageParser(model *UcumModel) *NaturalLanguageParser {
+	p := &NaturalLanguageParser{}
+	p.Model = model
+	p.units = make(map[string]Uniter)
+	add := func(name string, unit Uniter) {
+		words := strings.Fields(strings.ToLower(name))
+		if len(words) == 0 {
+			return
+		}
+		key := naturalKey(words)
+		if p.units[key] == nil {
+			p.units[key] = unit
+		}
+		if len(words) > p.maxWords {
+			p.maxWords = len(words)
+		}
+	}
+	for name, code := range preferredNames {
+		if unit := model.GetUnit(code); unit != nil {
+			add(name, unit)
+		}
+	}
+	//the units of the English translations are the units used in practice, "minute" is min, not the minute of arc
+	translations := englishTranslations()
+	codes := make([]string, 0)
+	for code := range translations.Units {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if unit := model.GetUnit(code); unit != nil {
+			add(translations.Units[code].One, unit)
+			add(translations.Units[code].Other, unit)
+		}
+	}
+	for _, u := range model.BaseUnits {
+		for _, name := range u.Names {
+			add(name, u)
+		}
+	}
+	for _, u := range model.DefinedUnits {
+		for _, name := range u.Names {
+			add(name, u)
+		}
+	}
+	p.prefixes = append(p.prefixes, model.Prefixes...)
+	sort.SliceStable(p.prefixes, func(i, j int) bool {
+		return len(prefixName(p.prefixes[i])) > len(prefixName(p.prefixes[j]))
+	})
+	return p
+}
+
+func prefixName(prefix *Prefix) string {
+	if len(prefix.Names) == 0 {
+		return ""
+	}
+	return strings.ToLower(prefix.Names[0])
+}
+
+// the key of a name, with the words in singular, as "degree celsiu" for "degrees Celsius"
+func naturalKey(words []string) string {
+	singular := make([]string, 0)
+	for _, w := range words {
+		if len(w) > 2 && strings.HasSuffix(w, "s") {
+			w = w[:len(w)-1]
+		}
+		singular = append(singular, w)
+	}
+	return strings.Join(singular, " ")
+}
+
+func isNaturalKeyword(word string) bool {
+	switch word {
+	case "per", "square", "cubic", "squared", "cubed", "to":
+		return true
+	}
+	return false
+}
+
+// parses the phrase, the result is a valid UCUM expression
+func (p *NaturalLanguageParser) Parse(phrase string) (string, error) {
+	words := strings.Fields(strings.ToLower(phrase))
+	if len(words) == 0 {
+		return "", fmt.Errorf("the phrase is empty")
+	}
+	groups := [][]string{{}}
+	for i := 0; i < len(words); {
+		word := words[i]
+		group := &groups[len(groups)-1]
+		if word == "per" {
+			groups = append(groups, []string{})
+			i++
+			continue
+		}
+		if _, err := strconv.Atoi(word); err == nil {
+			*group = append(*group, word)
+			i++
+			continue
+		}
+		if word == "ten" {
+			if n, exponent := p.parsePower(words[i+1:]); n > 0 {
+				*group = append(*group, "10*"+strconv.Itoa(exponent))
+				i += 1 + n
+				continue
+			}
+		}
+		exponent := 1
+		if word == "square" || word == "cubic" {
+			exponent = 2
+			if word == "cubic" {
+				exponent = 3
+			}
+			i++
+		}
+		prefix, unit, n := p.matchUnit(words[i:])
+		if unit == nil {
+			//a count noun is a group of its own, as "beats" in "beats per minute"
+			if i < len(words) && countNouns[words[i]] && len(*group) == 0 && exponent == 1 &&
+				(i+1 == len(words) || words[i+1] == "per") {
+				*group = append(*group, "{"+words[i]+"}")
+				i++
+				continue
+			}
+			if i >= len(words) {
+				return "", fmt.Errorf("expected a unit after '" + word + "'")
+			}
+			return "", fmt.Errorf("unknown unit '" + words[i] + "' in '" + phrase + "'")
+		}
+		i += n
+		if i < len(words) && (words[i] == "squared" || words[i] == "cubed") {
+			exponent = 2
+			if words[i] == "cubed" {
+				exponent = 3
+			}
+			i++
+		} else if n, e := p.parsePower(words[i:]); n > 0 {
+			exponent = e
+			i += n
+		}
+		code := unit.GetCode()
+		if prefix != nil {
+			code = prefix.Code + code
+		}
+		if exponent != 1 {
+			code += strconv.Itoa(exponent)
+		}
+		*group = append(*group, code)
+	}
+	result := strings.Join(groups[0], ".")
+	for _, group := range groups[1:] {
+		if len(group) == 0 {
+			return "", fmt.Errorf("expected a unit after 'per' in '" + phrase + "'")
+		}
+		if len(group) > 1 {
+			result += "/(" + strings.Join(group, ".") + ")"
+		} else {
+			result += "/" + group[0]
+		}
+	}
+	if _, err := NewExpressionParser(p.Model).Parse(result); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// parses "to the power [of] n", returns the number of words and n, 0 words if there is no power
+func (p *NaturalLanguageParser) parsePower(words []string) (int, int) {
+	if len(words) < 4 || words[0] != "to" || words[1] != "the" || words[2] != "power" {
+		return 0, 0
+	}
+	n := 3
+	if words[n] == "of" && len(words) > 4 {
+		n++
+	}
+	exponent, err := strconv.Atoi(words[n])
+	if err != nil {
+		return 0, 0
+	}
+	return n + 1, exponent
+}
+
+/**
+matches the longest unit name at the start of the words, with or without a prefix.
+Returns the prefix (nil if none), the unit (nil if no unit matches) and the number of words matched.
+ */
+func (p *NaturalLanguageParser) matchUnit(words []string) (*Prefix, Uniter, int) {
+	max := 0
+	for max < len(words) && max < p.maxWords && !isNaturalKeyword(words[max]) {
+		max++
+	}
+	for n := max; n > 0; n-- {
+		if unit := p.units[naturalKey(words[:n])]; unit != nil {
+			return nil, unit, n
+		}
+		for _, prefix := range p.prefixes {
+			name := prefixName(prefix)
+			if name == "" || !strings.HasPrefix(words[0], name) || len(words[0]) == len(name) {
+				continue
+			}
+			rest := append([]string{words[0][len(name):]}, words[1:n]...)
+			if unit := p.units[naturalKey(rest)]; unit != nil && isMetricUnit(unit) {
+				return prefix, unit, n
+			}
+		}
+	}
+	return nil, nil, 0
+}
+
+func isMetricUnit(unit Uniter) bool {
+	switch u := unit.(type) {
+	case *BaseUnit:
+		return true
+	case *DefinedUnit:
+		return u.Metric
+	}
+	return false
+}
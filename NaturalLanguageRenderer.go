@@ -0,0 +1,149 @@
+package ucum
+
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/bertverhees/ucum/decimal"
+)
+
+/**
+NaturalLanguageRenderer renders a parsed Term in English words, for screen readers and patient facing text,
+e.g. "milligrams per deciliter", "meters per second squared", "cubic centimeter", "per microliter".
+The term is read as a product: multiplied units are named first, each divided unit follows after "per",
+so mg/(kg.d) is "milligrams per kilogram per day". Exponents are "square" and "cubic" before the unit
+("kilograms per square meter"), but "squared" and "cubed" after a divided unit of time ("meters per second squared"),
+otherwise "to the power n". An annotation in place of a unit is read as a count noun, {beats}/min is "beats per minute",
+an annotation following a unit only qualifies it and is not read, mg{creat} is "milligrams".
+Translations names the units (the bundled English translations if nil), the words are English.
+ */
+type NaturalLanguageRenderer struct {
+	Translations *Translations
+}
+
+func NewNaturalLanguageRenderer(translations *Translations) *NaturalLanguageRenderer {
+	r := &NaturalLanguageRenderer{}
+	if translations == nil {
+		translations = englishTranslations()
+	}
+	r.Translations = translations
+	return r
+}
+
+// a symbol, factor or annotation of a flattened term, with a negative exponent if it is divided
+type naturalFactor struct {
+	symbol     *Symbol
+	value      int
+	annotation string
+	exponent   int
+}
+
+// renders the unit, the last multiplied unit in plural if plural is true, "unity" if the term has no units
+func (r *NaturalLanguageRenderer) Render(term *Term, plural bool) string {
+	collector := &naturalFactorCollector{}
+	WalkExpression(term, collector)
+	factors := collector.factors
+	numerator := make([]*naturalFactor, 0)
+	denominator := make([]*naturalFactor, 0)
+	for _, f := range factors {
+		if f.exponent > 0 {
+			numerator = append(numerator, f)
+		} else if f.exponent < 0 {
+			denominator = append(denominator, f)
+		}
+	}
+	if len(numerator) == 0 && len(denominator) == 0 {
+		return r.Translations.Unity
+	}
+	words := make([]string, 0)
+	for i, f := range numerator {
+		words = append(words, r.renderFactor(f, false, plural && i == len(numerator)-1))
+	}
+	for i := 0; i < len(denominator); i++ {
+		f := denominator[i]
+		//a number followed by a unit is read together, mL/(12.h) is "milliliters per 12 hours"
+		if f.symbol == nil && f.annotation == "" && i+1 < len(denominator) && denominator[i+1].symbol != nil {
+			words = append(words, "per "+strconv.Itoa(f.value)+" "+r.renderFactor(denominator[i+1], true, f.value != 1))
+			i++
+			continue
+		}
+		words = append(words, "per "+r.renderFactor(f, true, false))
+	}
+	return strings.Join(words, " ")
+}
+
+// renders a quantity, e.g. "5 milligrams per kilogram per day", "1 milligram per kilogram"
+func (r *NaturalLanguageRenderer) RenderQuantity(value decimal.Decimal, term *Term) string {
+	number := r.Translations.FormatNumber(value)
+	unit := r.Render(term, r.Translations.IsPlural(value))
+	if unit == r.Translations.Unity {
+		return number
+	}
+	return number + " " + unit
+}
+
+// collects the symbols, factors and annotations of the term, divided ones with a negative exponent;
+// factors 1 and annotations following a unit are dropped
+type naturalFactorCollector struct {
+	BaseExpressionVisitor
+	factors  []*naturalFactor
+	symbol   bool //the last component was a symbol
+	inverted bool //the last component was divided
+}
+
+func (v *naturalFactorCollector) VisitSymbol(symbol *Symbol, inverted bool) {
+	f := &naturalFactor{symbol: symbol, exponent: symbol.Exponent}
+	if inverted {
+		f.exponent = -f.exponent
+	}
+	v.factors = append(v.factors, f)
+	v.symbol, v.inverted = true, inverted
+}
+
+func (v *naturalFactorCollector) VisitFactor(factor *Factor, inverted bool) {
+	afterSymbol := v.symbol && v.inverted == inverted
+	v.symbol, v.inverted = false, inverted
+	if factor.Annotation != "" && afterSymbol {
+		return
+	}
+	if factor.Value != 1 || factor.Annotation != "" {
+		f := &naturalFactor{value: factor.Value, annotation: factor.Annotation, exponent: 1}
+		if inverted {
+			f.exponent = -1
+		}
+		v.factors = append(v.factors, f)
+	}
+}
+
+func (r *NaturalLanguageRenderer) renderFactor(f *naturalFactor, divided bool, plural bool) string {
+	if f.symbol == nil && f.annotation != "" {
+		return f.annotation
+	}
+	if f.symbol == nil {
+		return strconv.Itoa(f.value)
+	}
+	exponent := f.exponent
+	if exponent < 0 {
+		exponent = -exponent
+	}
+	code := f.symbol.Unit.GetCode()
+	if f.symbol.Prefix == nil && (code == "10*" || code == "10^") {
+		return "ten to the power " + strconv.Itoa(exponent)
+	}
+	name := r.Translations.SymbolName(f.symbol, plural)
+	time := divided && f.symbol.Unit.GetProperty() == "time"
+	switch {
+	case exponent == 1:
+		return name
+	case exponent == 2 && time:
+		return name + " squared"
+	case exponent == 2:
+		return "square " + name
+	case exponent == 3 && time:
+		return name + " cubed"
+	case exponent == 3:
+		return "cubic " + name
+	}
+	return name + " to the power " + strconv.Itoa(exponent)
+}
@@ -0,0 +1,97 @@
+package ucum
+
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/**
+NormalizeTerm returns the normal form of a term, an expression with the same atoms and prefixes,
+which is equal for equivalent ways of writing a unit: mg/dL, mg.dL-1 and /dL.mg are all "mg/dL".
+- the term is flattened (see FlattenTerm): repeated symbols are merged, and a symbol which cancels out is dropped
+- the multiplied symbols are written first, sorted on atom code and prefix code, preceded by the factor if not 1
+- the divided symbols follow after a single solidus, sorted in the same way, in parentheses if more than one
+- a unit without symbols and factor is "1"
+Returns an error if the product of the factors overflows.
+Different prefixes of an atom are not merged (mg.g stays g.mg). Annotations are not part of the normal form,
+so mg{creat} and mg have the same normal form "mg", as have {beats}/min and /min.
+ */
+func NormalizeTerm(term *Term) (string, error) {
+	flat, err := FlattenTerm(term)
+	if err != nil {
+		return "", err
+	}
+	numerator := make([]*Symbol, 0)
+	denominator := make([]*Symbol, 0)
+	for _, s := range flat.Symbols {
+		if s.Exponent > 0 {
+			numerator = append(numerator, s)
+		} else {
+			denominator = append(denominator, s)
+		}
+	}
+	sortSymbols(numerator)
+	sortSymbols(denominator)
+	multiplied := make([]string, 0)
+	if flat.Numerator != 1 {
+		multiplied = append(multiplied, strconv.Itoa(flat.Numerator))
+	}
+	for _, s := range numerator {
+		multiplied = append(multiplied, normalSymbol(s, s.Exponent))
+	}
+	divided := make([]string, 0)
+	if flat.Denominator != 1 {
+		divided = append(divided, strconv.Itoa(flat.Denominator))
+	}
+	for _, s := range denominator {
+		divided = append(divided, normalSymbol(s, -s.Exponent))
+	}
+	result := strings.Join(multiplied, ".")
+	switch len(divided) {
+	case 0:
+		if result == "" {
+			return "1", nil
+		}
+	case 1:
+		result += "/" + divided[0]
+	default:
+		result += "/(" + strings.Join(divided, ".") + ")"
+	}
+	return result, nil
+}
+
+// parses the unit and returns its normal form, see NormalizeTerm
+func NormalizeUnit(model *UcumModel, unit string) (string, error) {
+	term, err := NewExpressionParser(model).Parse(unit)
+	if err != nil {
+		return "", err
+	}
+	return NormalizeTerm(term)
+}
+
+func sortSymbols(symbols []*Symbol) {
+	sort.SliceStable(symbols, func(i, j int) bool {
+		a, b := symbols[i], symbols[j]
+		if a.Unit.GetCode() != b.Unit.GetCode() {
+			return a.Unit.GetCode() < b.Unit.GetCode()
+		}
+		return prefixCode(a) < prefixCode(b)
+	})
+}
+
+func prefixCode(symbol *Symbol) string {
+	if symbol.Prefix == nil {
+		return ""
+	}
+	return symbol.Prefix.Code
+}
+
+func normalSymbol(symbol *Symbol, exponent int) string {
+	code := prefixCode(symbol) + symbol.Unit.GetCode()
+	if exponent != 1 {
+		code += strconv.Itoa(exponent)
+	}
+	return code
+}
@@ -0,0 +1,181 @@
+package ucum
+
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/bertverhees/ucum/decimal"
+)
+
+/**
+An overlay adds site-local units to a model, without editing the official essence file.
+In XML, the units are written as in the essence file:
+	<overlay source="St. Elsewhere">
+		<unit Code="[drp_local]" CODE="[DRP_LOCAL]" isMetric="no" class="local">
+			<name>local drop</name>
+			<printSymbol>drp</printSymbol>
+			<property>volume</property>
+			<value Unit="mL" UNIT="ML" value="0.05">0.05</value>
+		</unit>
+	</overlay>
+In JSON:
+	{"source": "St. Elsewhere", "units": [{"code": "[drp_local]", "codeUC": "[DRP_LOCAL]",
+	 "names": ["local drop"], "printSymbol": "drp", "property": "volume", "class": "local",
+	 "value": {"unit": "mL", "unitUC": "ML", "value": "0.05"}}]}
+Every unit must have a positive value. The units get the source as Provenance.
+ */
+type XMLOverlay struct {
+	Source       string           `xml:"source,attr"`
+	DefinedUnits []XMLDefinedUnit `xml:"unit"`
+}
+
+type JSONOverlay struct {
+	Source string            `json:"source"`
+	Units  []JSONDefinedUnit `json:"units"`
+}
+
+type JSONDefinedUnit struct {
+	Code        string    `json:"code"`
+	CodeUC      string    `json:"codeUC"`
+	Names       []string  `json:"names"`
+	PrintSymbol string    `json:"printSymbol"`
+	Property    string    `json:"property"`
+	Class       string    `json:"class"`
+	IsMetric    bool      `json:"isMetric"`
+	IsArbitrary bool      `json:"isArbitrary"`
+	Value       JSONValue `json:"value"`
+}
+
+type JSONValue struct {
+	Unit   string `json:"unit"`
+	UnitUC string `json:"unitUC"`
+	Value  string `json:"value"`
+}
+
+// the provenance of overlay units without a source
+const DEFAULT_OVERLAY_SOURCE = "overlay"
+
+// reads an overlay in XML and merges it into the model, see UcumModel.MergeUnits; not safe while the model is in use
+func (d *DefinitionParser) MergeOverlay(model *UcumModel, reader io.Reader) error {
+	overlay := &XMLOverlay{}
+	if err := xml.NewDecoder(reader).Decode(overlay); err != nil {
+		return err
+	}
+	units := make([]*DefinedUnit, 0)
+	for _, xmlItem := range overlay.DefinedUnits {
+		unit, err := xmlItem.definedUnit()
+		if err != nil {
+			return err
+		}
+		if !unit.IsSpecial && unit.Value.Value.Sign() <= 0 {
+			return fmt.Errorf("overlay unit " + unit.Code + " must have a positive value")
+		}
+		units = append(units, unit)
+	}
+	return model.MergeUnits(overlay.Source, units)
+}
+
+// reads an overlay in JSON and merges it into the model, see UcumModel.MergeUnits; not safe while the model is in use
+func (d *DefinitionParser) MergeOverlayJSON(model *UcumModel, reader io.Reader) error {
+	overlay := &JSONOverlay{}
+	if err := json.NewDecoder(reader).Decode(overlay); err != nil {
+		return err
+	}
+	units := make([]*DefinedUnit, 0)
+	for _, item := range overlay.Units {
+		unit := &DefinedUnit{}
+		unit.Kind = UNIT
+		unit.Code = item.Code
+		unit.CodeUC = item.CodeUC
+		unit.Names = item.Names
+		unit.PrintSymbol = item.PrintSymbol
+		unit.Property = item.Property
+		unit.Class = item.Class
+		unit.Metric = item.IsMetric
+		unit.IsArbitrary = item.IsArbitrary
+		value, err := NewValue(item.Value.Unit, item.Value.UnitUC, decimal.Zero)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(item.Value.Value) == "" {
+			return fmt.Errorf("overlay unit " + item.Code + " has no value")
+		}
+		value.Value, err = decimal.NewFromString(strings.TrimSpace(item.Value.Value))
+		if err != nil {
+			return err
+		}
+		if value.Value.Sign() <= 0 {
+			return fmt.Errorf("overlay unit " + item.Code + " must have a positive value")
+		}
+		unit.Value = value
+		units = append(units, unit)
+	}
+	return model.MergeUnits(overlay.Source, units)
+}
+
+/**
+MergeUnits adds the units to the model, with source as Provenance.
+The units must not conflict with the units of the model: codes must be new, definitions must refer to
+known units, and prefixed metric units must not collide with other units (see IntegrityChecker).
+Special units can not be added, there is no handler for them.
+If there is a conflict, nothing is added and an IntegrityError with all conflicts is returned.
+MergeUnits changes the model in place without locking (the units, the property list, the search index
+and the phrase parser), so it may only be called before the model is shared, e.g. right after loading it
+and before it is handed to other goroutines.
+ */
+func (u *UcumModel) MergeUnits(source string, units []*DefinedUnit) error {
+	if source == "" {
+		source = DEFAULT_OVERLAY_SOURCE
+	}
+	problems := make([]string, 0)
+	for _, unit := range units {
+		if unit.IsSpecial {
+			problems = append(problems, "special unit "+unit.Code+" can not be added by an overlay")
+		}
+	}
+	//check the model with the units, before changing it
+	known := make(map[string]bool)
+	for _, p := range NewIntegrityChecker(u).Check() {
+		known[p] = true
+	}
+	trial := *u
+	trial.DefinedUnits = append(append(make([]*DefinedUnit, 0), u.DefinedUnits...), units...)
+	trial.DefinedUnitsByCode = make(map[string]*DefinedUnit)
+	for _, unit := range trial.DefinedUnits {
+		if trial.DefinedUnitsByCode[unit.Code] == nil {
+			trial.DefinedUnitsByCode[unit.Code] = unit
+		}
+	}
+	for _, p := range NewIntegrityChecker(&trial).Check() {
+		if !known[p] {
+			problems = append(problems, p)
+		}
+	}
+	if len(problems) > 0 {
+		return NewIntegrityError(problems)
+	}
+	for _, unit := range units {
+		unit.Provenance = source
+		u.addDefinedUnit(unit)
+	}
+	sort.Strings(u.PropertyList)
+	u.SearchIndex = NewSearchIndex(u)
+	u.naturalParser = NewNaturalLanguageParser(u)
+	return nil
+}
+
+// returns the units added by overlays
+func (u *UcumModel) GetLocalUnits() []*DefinedUnit {
+	result := make([]*DefinedUnit, 0)
+	for _, unit := range u.DefinedUnits {
+		if unit.IsLocal() {
+			result = append(result, unit)
+		}
+	}
+	return result
+}
@@ -0,0 +1,183 @@
+package ucum
+
+
+import (
+	"bytes"
+	"html"
+	"strconv"
+)
+
+/**
+PrintStyle is the typography of a rendered print symbol, e.g. for mg/dL.m2:
+- PRINT_UNICODE: "mg/dL·m²", superscripts in Unicode characters
+- PRINT_HTML: "mg/dL&middot;m<sup>2</sup>"
+- PRINT_LATEX: "\mathrm{mg}/\mathrm{dL}\cdot\mathrm{m}^{2}", for use in math mode
+- PRINT_ASCII: "mg/dL.m^2", print symbols which are not ASCII are replaced by the UCUM code (μg -> ug, °C -> Cel)
+ */
+type PrintStyle int
+
+const (
+	PRINT_UNICODE PrintStyle = iota
+	PRINT_HTML
+	PRINT_LATEX
+	PRINT_ASCII
+)
+
+//PrintSymbolRenderer=====================================================
+/**
+PrintSymbolRenderer renders a parsed Term with the print symbols of its prefixes and units,
+e.g. "μg", "°C", "10⁹/L". A concept without print symbol is rendered by its code.
+Annotations are rendered in braces after the component they annotate, e.g. "mg{total}", "{rbc}/μL".
+ */
+type PrintSymbolRenderer struct {
+	Style PrintStyle
+}
+
+func NewPrintSymbolRenderer(style PrintStyle) *PrintSymbolRenderer {
+	r := &PrintSymbolRenderer{}
+	r.Style = style
+	return r
+}
+
+func (r *PrintSymbolRenderer) Render(term *Term) string {
+	var buffer bytes.Buffer
+	r.renderTerm(&buffer, term)
+	return buffer.String()
+}
+
+func (r *PrintSymbolRenderer) renderTerm(buffer *bytes.Buffer, term *Term) {
+	if term.Comp != nil {
+		r.renderComp(buffer, term.Comp)
+	} else if term.Op == DIVISION {
+		//a leading solidus, /min is rendered as 1/min
+		buffer.WriteString("1")
+	}
+	//an annotation follows the component it annotates without operator
+	if term.Op > 0 && !(term.Op == MULTIPLICATION && isAnnotation(term.Term)) {
+		r.renderOp(buffer, term.Op)
+	}
+	if term.Term != nil {
+		r.renderTerm(buffer, term.Term)
+	}
+}
+
+func (r *PrintSymbolRenderer) renderComp(buffer *bytes.Buffer, comp Componenter) {
+	switch c := comp.(type) {
+	case *Factor:
+		if c.Annotation != "" {
+			r.renderAnnotation(buffer, c.Annotation)
+			return
+		}
+		buffer.WriteString(strconv.Itoa(c.Value))
+	case *Symbol:
+		r.renderSymbol(buffer, c)
+	case *Term:
+		buffer.WriteString("(")
+		r.renderTerm(buffer, c)
+		buffer.WriteString(")")
+	default:
+		buffer.WriteString("?")
+	}
+}
+
+func (r *PrintSymbolRenderer) renderAnnotation(buffer *bytes.Buffer, annotation string) {
+	text := "{" + annotation + "}"
+	switch r.Style {
+	case PRINT_HTML:
+		buffer.WriteString(html.EscapeString(text))
+	case PRINT_LATEX:
+		buffer.WriteString(`\mathrm{` + EscapeLaTeX(text) + "}")
+	default:
+		buffer.WriteString(text)
+	}
+}
+
+func (r *PrintSymbolRenderer) renderSymbol(buffer *bytes.Buffer, symbol *Symbol) {
+	text := ""
+	if symbol.Prefix != nil {
+		text = r.printSymbol(symbol.Prefix)
+	}
+	text += r.printSymbol(symbol.Unit)
+	if r.Style == PRINT_LATEX {
+		text = `\mathrm{` + text + "}"
+	}
+	buffer.WriteString(text)
+	if symbol.Exponent != 1 {
+		r.renderExponent(buffer, symbol.Exponent)
+	}
+}
+
+func (r *PrintSymbolRenderer) printSymbol(concept Concepter) string {
+	var markup *Markup
+	if m, instanceof := concept.(MarkupConcepter); instanceof {
+		markup = m.GetPrintSymbolMarkup()
+	}
+	if markup == nil && concept.GetPrintSymbol() != "" {
+		//concepts of an overlay in JSON have a print symbol without markup
+		markup = NewMarkup(html.EscapeString(concept.GetPrintSymbol()))
+	}
+	switch r.Style {
+	case PRINT_HTML:
+		if markup != nil {
+			return markup.HTML()
+		}
+		return html.EscapeString(concept.GetCode())
+	case PRINT_LATEX:
+		if markup != nil {
+			return markup.LaTeX()
+		}
+		return EscapeLaTeX(concept.GetCode())
+	case PRINT_ASCII:
+		if markup != nil {
+			if s := markup.Plain(); isASCII(s) {
+				return s
+			}
+		}
+		return concept.GetCode()
+	}
+	if markup != nil {
+		return markup.Unicode()
+	}
+	return concept.GetCode()
+}
+
+func (r *PrintSymbolRenderer) renderExponent(buffer *bytes.Buffer, exponent int) {
+	e := strconv.Itoa(exponent)
+	switch r.Style {
+	case PRINT_HTML:
+		buffer.WriteString("<sup>" + e + "</sup>")
+	case PRINT_LATEX:
+		buffer.WriteString("^{" + e + "}")
+	case PRINT_ASCII:
+		buffer.WriteString("^" + e)
+	default:
+		s, _ := ToSuperscript(e)
+		buffer.WriteString(s)
+	}
+}
+
+func (r *PrintSymbolRenderer) renderOp(buffer *bytes.Buffer, op Operator) {
+	if op == DIVISION {
+		buffer.WriteString("/")
+		return
+	}
+	switch r.Style {
+	case PRINT_HTML:
+		buffer.WriteString("&middot;")
+	case PRINT_LATEX:
+		buffer.WriteString(`\cdot`)
+	case PRINT_ASCII:
+		buffer.WriteString(".")
+	default:
+		buffer.WriteString("·")
+	}
+}
+
+func isASCII(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] > 127 {
+			return false
+		}
+	}
+	return true
+}
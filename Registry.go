@@ -41,3 +41,20 @@ func (r *Registry) Exists(code string) bool {
 func (r *Registry) Get(code string) SpecialUnitHandlerer {
 	return r.handlers[code]
 }
+
+// returns true if both registries have the same handlers, with the same units and values
+func (r *Registry) sameAs(other *Registry) bool {
+	if r == other {
+		return true
+	}
+	if r == nil || other == nil || len(r.handlers) != len(other.handlers) {
+		return false
+	}
+	for code, handler := range r.handlers {
+		o := other.handlers[code]
+		if o == nil || o.GetUnits() != handler.GetUnits() || !o.GetValue().Equal(handler.GetValue()) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,408 @@
+package ucum
+
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+/**
+Ranking of a search result, higher is better
+- MATCH_EXACT_CODE = the text is the (case sensitive) code of the concept
+- MATCH_EXACT_CODE_UC = the text is the code of the concept, ignoring case
+- MATCH_PREFIX = the code or a name of the concept starts with the text
+- MATCH_TOKEN = every word of the text is a word of a name, print symbol, property or class of the concept
+- MATCH_FUZZY = every word of the text is the start of, or one typo away from, a word of the concept
+ */
+const (
+	MATCH_FUZZY = iota + 1
+	MATCH_TOKEN
+	MATCH_PREFIX
+	MATCH_EXACT_CODE_UC
+	MATCH_EXACT_CODE
+)
+
+type SearchResult struct {
+	Concept Concepter
+	Rank    int
+}
+
+/**
+SearchIndex is an inverted index over the codes, c/i codes, names, print symbols,
+properties and classes of the concepts in a model. It is built when the model is loaded,
+and only read afterwards, so searches may run concurrently.
+ */
+type SearchIndex struct {
+	model    *UcumModel
+	concepts []Concepter
+	exact    map[string][]int    //code -> concepts
+	codes    map[string][]int    //lower case code -> concepts
+	tokens   map[string][]int    //lower case word -> concepts
+	words    []string            //keys of tokens, sorted
+	names    [][]string          //lower case names, per concept
+	starts   []string            //lower case codes and names, sorted
+	startIds map[string][]int    //lower case code or name -> concepts
+	typos    map[string][]string //word with one letter deleted -> words
+	metric   []Uniter            //units that may be prefixed
+}
+
+func NewSearchIndex(model *UcumModel) *SearchIndex {
+	s := &SearchIndex{}
+	s.model = model
+	s.concepts = make([]Concepter, 0)
+	s.exact = make(map[string][]int)
+	s.codes = make(map[string][]int)
+	s.tokens = make(map[string][]int)
+	s.names = make([][]string, 0)
+	for _, p := range model.Prefixes {
+		s.add(p, "", "")
+	}
+	for _, b := range model.BaseUnits {
+		s.add(b, b.Property, "")
+	}
+	for _, d := range model.DefinedUnits {
+		s.add(d, d.Property, d.Class)
+	}
+	s.words = make([]string, 0, len(s.tokens))
+	for w := range s.tokens {
+		s.words = append(s.words, w)
+	}
+	sort.Strings(s.words)
+	s.derive()
+	return s
+}
+
+// builds the lookups derived from the model, concepts, names and words, which are not saved in a snapshot
+func (s *SearchIndex) derive() {
+	s.startIds = make(map[string][]int)
+	for id, concept := range s.concepts {
+		for _, key := range append([]string{strings.ToLower(concept.GetCode())}, s.names[id]...) {
+			list := s.startIds[key]
+			if len(list) == 0 || list[len(list)-1] != id {
+				s.startIds[key] = append(list, id)
+			}
+		}
+	}
+	s.starts = make([]string, 0, len(s.startIds))
+	for key := range s.startIds {
+		s.starts = append(s.starts, key)
+	}
+	sort.Strings(s.starts)
+	s.typos = make(map[string][]string)
+	for _, w := range s.words {
+		for _, d := range deletions(w) {
+			s.typos[d] = append(s.typos[d], w)
+		}
+	}
+	s.metric = s.metricUnits()
+}
+
+// returns the word with each of its letters deleted in turn
+func deletions(word string) []string {
+	runes := []rune(word)
+	result := make([]string, 0, len(runes))
+	for i := range runes {
+		result = append(result, string(runes[:i])+string(runes[i+1:]))
+	}
+	return result
+}
+
+// returns the range of the sorted keys starting with prefix
+func prefixRange(keys []string, prefix string) []string {
+	from := sort.SearchStrings(keys, prefix)
+	to := from
+	for to < len(keys) && strings.HasPrefix(keys[to], prefix) {
+		to++
+	}
+	return keys[from:to]
+}
+
+func (s *SearchIndex) add(concept Concepter, property, class string) {
+	id := len(s.concepts)
+	s.concepts = append(s.concepts, concept)
+	addId := func(index map[string][]int, key string) {
+		list := index[key]
+		if len(list) == 0 || list[len(list)-1] != id {
+			index[key] = append(list, id)
+		}
+	}
+	addId(s.exact, concept.GetCode())
+	addId(s.codes, strings.ToLower(concept.GetCode()))
+	addId(s.codes, strings.ToLower(concept.GetCodeUC()))
+	names := make([]string, 0)
+	for _, name := range concept.GetNames() {
+		names = append(names, strings.ToLower(name))
+	}
+	s.names = append(s.names, names)
+	texts := []string{concept.GetPrintSymbol(), property, class}
+	for _, text := range append(texts, concept.GetNames()...) {
+		for _, w := range tokenize(text) {
+			addId(s.tokens, w)
+		}
+	}
+}
+
+// splits the text in lower case words
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+/**
+Search returns the concepts matching the text, best ranked first. Concepts with the same rank are
+ordered on code length, code and kind.
+offset and limit select a page of the results, limit <= 0 returns all results from offset.
+ */
+func (s *SearchIndex) Search(text string, offset, limit int) []*SearchResult {
+	ranks := s.rank(text)
+	results := make([]*SearchResult, 0, len(ranks))
+	for id, rank := range ranks {
+		results = append(results, &SearchResult{Concept: s.concepts[id], Rank: rank})
+	}
+	return page(results, offset, limit)
+}
+
+/**
+SearchUnits is like Search, but only returns units, and includes prefixed forms of metric units
+(e.g. "mg", "kPa", "milligram"), which are not concepts of the model.
+If composite, a text like "milligram per deciliter" or "mg/dL" is also recognised as a composite unit.
+ */
+func (s *SearchIndex) SearchUnits(text string, composite bool, offset, limit int) []*SearchResult {
+	results := s.searchUnits(text)
+	if composite {
+		results = append(results, s.searchComposite(text)...)
+	}
+	return page(results, offset, limit)
+}
+
+// sorts the results on rank, code length, code and kind, and returns the page from offset
+func page(results []*SearchResult, offset, limit int) []*SearchResult {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		ci, cj := results[i].Concept.GetCode(), results[j].Concept.GetCode()
+		if len(ci) != len(cj) {
+			return len(ci) < len(cj)
+		}
+		if ci != cj {
+			return ci < cj
+		}
+		return results[i].Concept.GetKind() < results[j].Concept.GetKind()
+	})
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(results) {
+		offset = len(results)
+	}
+	results = results[offset:]
+	if limit > 0 && limit < len(results) {
+		results = results[:limit]
+	}
+	return results
+}
+
+// returns the units and prefixed metric units matching the text, unsorted
+func (s *SearchIndex) searchUnits(text string) []*SearchResult {
+	results := make([]*SearchResult, 0)
+	found := make(map[string]bool)
+	for id, rank := range s.rank(text) {
+		if unit, instanceof := s.concepts[id].(Uniter); instanceof {
+			results = append(results, &SearchResult{Concept: unit, Rank: rank})
+			found[unit.GetCode()] = true
+		}
+	}
+	text = strings.TrimSpace(text)
+	lower := strings.ToLower(text)
+	add := func(prefix *Prefix, unit Uniter, rank int) {
+		p := NewPrefixedUnit(prefix, unit)
+		if !found[p.Code] {
+			results = append(results, &SearchResult{Concept: p, Rank: rank})
+			found[p.Code] = true
+		}
+	}
+	for _, prefix := range s.model.Prefixes {
+		name := ""
+		if len(prefix.Names) > 0 {
+			name = strings.ToLower(prefix.Names[0])
+		}
+		for _, unit := range s.metric {
+			if text == prefix.Code+unit.GetCode() {
+				add(prefix, unit, MATCH_EXACT_CODE)
+			} else if lower == strings.ToLower(prefix.CodeUC+unit.GetCodeUC()) {
+				add(prefix, unit, MATCH_EXACT_CODE_UC)
+			}
+		}
+		if name == "" || !strings.HasPrefix(lower, name) || len(lower) == len(name) {
+			continue
+		}
+		rest := lower[len(name):]
+		for _, unit := range s.metric {
+			for _, n := range unit.GetNames() {
+				if strings.HasPrefix(strings.ToLower(n), rest) {
+					add(prefix, unit, MATCH_PREFIX)
+					break
+				}
+			}
+		}
+	}
+	return results
+}
+
+var perRegex = regexp.MustCompile(`(?i)\s+per\s+`)
+
+// returns the best matching composite unit, if every part of the text divided by "per" or "/" matches a unit
+func (s *SearchIndex) searchComposite(text string) []*SearchResult {
+	texts := strings.Split(perRegex.ReplaceAllString(strings.TrimSpace(text), "/"), "/")
+	if len(texts) < 2 {
+		return nil
+	}
+	parts := make([]Uniter, 0)
+	rank := MATCH_EXACT_CODE
+	for _, t := range texts {
+		best := page(s.searchUnits(t), 0, 1)
+		if len(best) == 0 {
+			return nil
+		}
+		parts = append(parts, best[0].Concept.(Uniter))
+		rank = MinInt(rank, best[0].Rank)
+	}
+	c := NewCompositeUnit(parts)
+	if _, err := NewExpressionParser(s.model).Parse(c.Code); err != nil {
+		return nil
+	}
+	return []*SearchResult{{Concept: c, Rank: rank}}
+}
+
+// returns the units that may be prefixed
+func (s *SearchIndex) metricUnits() []Uniter {
+	result := make([]Uniter, 0)
+	for _, unit := range s.model.BaseUnits {
+		result = append(result, unit)
+	}
+	for _, unit := range s.model.DefinedUnits {
+		if unit.Metric {
+			result = append(result, unit)
+		}
+	}
+	return result
+}
+
+// returns the rank per concept id
+func (s *SearchIndex) rank(text string) map[int]int {
+	ranks := make(map[int]int)
+	setRank := func(id, rank int) {
+		if ranks[id] < rank {
+			ranks[id] = rank
+		}
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ranks
+	}
+	lower := strings.ToLower(text)
+	for _, id := range s.exact[text] {
+		setRank(id, MATCH_EXACT_CODE)
+	}
+	for _, id := range s.codes[lower] {
+		setRank(id, MATCH_EXACT_CODE_UC)
+	}
+	for _, key := range prefixRange(s.starts, lower) {
+		for _, id := range s.startIds[key] {
+			setRank(id, MATCH_PREFIX)
+		}
+	}
+	words := tokenize(text)
+	if len(words) == 0 {
+		return ranks
+	}
+	for id := range s.matchWords(words, s.exactWord) {
+		setRank(id, MATCH_TOKEN)
+	}
+	for id := range s.matchWords(words, s.fuzzyWord) {
+		setRank(id, MATCH_FUZZY)
+	}
+	return ranks
+}
+
+// returns the concepts that match every word, using match to find the concepts matching a word
+func (s *SearchIndex) matchWords(words []string, match func(word string) map[int]bool) map[int]bool {
+	var result map[int]bool
+	for _, w := range words {
+		found := match(w)
+		if result == nil {
+			result = found
+		} else {
+			for id := range result {
+				if !found[id] {
+					delete(result, id)
+				}
+			}
+		}
+	}
+	return result
+}
+
+func (s *SearchIndex) exactWord(word string) map[int]bool {
+	result := make(map[int]bool)
+	for _, id := range s.tokens[word] {
+		result[id] = true
+	}
+	return result
+}
+
+func (s *SearchIndex) fuzzyWord(word string) map[int]bool {
+	result := make(map[int]bool)
+	add := func(w string) {
+		for _, id := range s.tokens[w] {
+			result[id] = true
+		}
+	}
+	for _, w := range prefixRange(s.words, word) {
+		add(w)
+	}
+	if len([]rune(word)) <= 3 {
+		return result
+	}
+	//a word one typo away shares a deletion with the text, or is the text with a letter deleted or inserted
+	candidates := append([]string{word}, s.typos[word]...)
+	for _, d := range deletions(word) {
+		candidates = append(candidates, d)
+		candidates = append(candidates, s.typos[d]...)
+	}
+	for _, w := range candidates {
+		if _, found := s.tokens[w]; found && levenshtein(w, word) <= 1 {
+			add(w)
+		}
+	}
+	return result
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	//only distances up to 1 are of interest
+	if len(ra)-len(rb) > 1 || len(rb)-len(ra) > 1 {
+		return 2
+	}
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = MinInt(MinInt(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
@@ -0,0 +1,77 @@
+package ucum
+
+
+/**
+SearchOptions define the scope of a search through the concepts of a model.
+Kinds = the kinds of concepts to search, all kinds if empty
+Class = only units of this class, if not empty (base units and prefixes have no class)
+Property = only units of this property, if not empty
+OnIsMetric, OnIsSpecial, OnIsArbitrary = only units with IsMetric, IsSpecial, IsArbitrary,
+if switched on. Base units are metric, not special and not arbitrary.
+Prefixes are only found if no unit filter is set.
+Limit = maximum number of results, all if <= 0
+ */
+type SearchOptions struct {
+	Kinds         []ConceptKind
+	Class         string
+	Property      string
+	OnIsMetric    bool
+	IsMetric      bool
+	OnIsSpecial   bool
+	IsSpecial     bool
+	OnIsArbitrary bool
+	IsArbitrary   bool
+	Limit         int
+}
+
+// without kinds, all kinds of concepts are searched
+func NewSearchOptions(kinds ...ConceptKind) *SearchOptions {
+	o := &SearchOptions{}
+	o.Kinds = kinds
+	return o
+}
+
+func (o *SearchOptions) HasKind(kind ConceptKind) bool {
+	if len(o.Kinds) == 0 {
+		return true
+	}
+	for _, k := range o.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// true if any filter is set which only applies to units
+func (o *SearchOptions) filtersUnits() bool {
+	return o.Class != "" || o.Property != "" || o.OnIsMetric || o.OnIsSpecial || o.OnIsArbitrary
+}
+
+// Filter applies the class, property and flags of the options to the defined units of duf
+func (o *SearchOptions) Filter(duf *DefinedUnitFilter) *DefinedUnitFilter {
+	if o.Class != "" {
+		duf = duf.FilterByClass(o.Class)
+	}
+	if o.Property != "" {
+		duf = duf.FilterByProperty(o.Property)
+	}
+	if o.OnIsMetric {
+		duf = duf.FilterByIsMetric(o.IsMetric)
+	}
+	if o.OnIsSpecial {
+		duf = duf.FilterByIsSpecial(o.IsSpecial)
+	}
+	if o.OnIsArbitrary {
+		duf = duf.FilterByIsArbitrary(o.IsArbitrary)
+	}
+	return duf
+}
+
+func (o *SearchOptions) acceptsBaseUnit(unit *BaseUnit) bool {
+	return o.Class == "" &&
+		(o.Property == "" || o.Property == unit.Property) &&
+		(!o.OnIsMetric || o.IsMetric) &&
+		(!o.OnIsSpecial || !o.IsSpecial) &&
+		(!o.OnIsArbitrary || !o.IsArbitrary)
+}
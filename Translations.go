@@ -0,0 +1,287 @@
+package ucum
+
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bertverhees/ucum/decimal"
+)
+
+/**
+Translations holds the names of concepts in one language, keyed by concept code.
+Prefixes and Units are separate, "G" is both giga and gauss.
+A concept without translation is named by its official (English) name.
+DecimalSeparator and GroupSeparator are used to format numbers, Unity names the unit 1.
+In JSON:
+	{"language": "nl", "decimalSeparator": ",", "groupSeparator": ".", "unity": "één",
+	 "prefixes": {"m": "milli"}, "units": {"min": {"one": "minuut", "other": "minuten"}}}
+ */
+type Translations struct {
+	Language         string                `json:"language"`
+	DecimalSeparator string                `json:"decimalSeparator"`
+	GroupSeparator   string                `json:"groupSeparator"`
+	Unity            string                `json:"unity"`
+	Prefixes         map[string]string     `json:"prefixes"`
+	Units            map[string]*UnitNames `json:"units"`
+}
+
+// the singular (One) and plural (Other) name of a unit, Other is One if empty
+type UnitNames struct {
+	One   string `json:"one"`
+	Other string `json:"other"`
+}
+
+func NewTranslations(language, decimalSeparator, groupSeparator, unity string) *Translations {
+	t := &Translations{}
+	t.Language = language
+	t.DecimalSeparator = decimalSeparator
+	t.GroupSeparator = groupSeparator
+	t.Unity = unity
+	t.Prefixes = make(map[string]string)
+	t.Units = make(map[string]*UnitNames)
+	return t
+}
+
+// reads translations in JSON, a missing decimal separator is ".", a missing unity is "unity"
+func ReadTranslations(reader io.Reader) (*Translations, error) {
+	t := NewTranslations("", ".", "", "unity")
+	if err := json.NewDecoder(reader).Decode(t); err != nil {
+		return nil, err
+	}
+	if t.Language == "" {
+		return nil, fmt.Errorf("translations must have a language")
+	}
+	if t.Prefixes == nil {
+		t.Prefixes = make(map[string]string)
+	}
+	if t.Units == nil {
+		t.Units = make(map[string]*UnitNames)
+	}
+	return t, nil
+}
+
+func (t *Translations) AddUnit(code, one, other string) {
+	t.Units[code] = &UnitNames{One: one, Other: other}
+}
+
+// returns the name of the concept, the plural name if plural is true
+func (t *Translations) Name(concept Concepter, plural bool) string {
+	if concept.GetKind() == PREFIX {
+		if name, found := t.Prefixes[concept.GetCode()]; found {
+			return name
+		}
+	} else if names := t.Units[concept.GetCode()]; names != nil {
+		if plural && names.Other != "" {
+			return names.Other
+		}
+		return names.One
+	}
+	if len(concept.GetNames()) == 0 {
+		return concept.GetCode()
+	}
+	return concept.GetNames()[0]
+}
+
+// returns the name of a unit with an optional prefix, e.g. "milligram", "Milligramm"
+func (t *Translations) SymbolName(symbol *Symbol, plural bool) string {
+	name := t.Name(symbol.Unit, plural)
+	if symbol.Prefix == nil {
+		return name
+	}
+	return t.Name(symbol.Prefix, false) + lowerFirst(name)
+}
+
+func lowerFirst(text string) string {
+	r, size := utf8.DecodeRuneInString(text)
+	return string(unicode.ToLower(r)) + text[size:]
+}
+
+/**
+the plural rules by language, true if a quantity of the value takes the singular.
+The rule of English applies to languages without a rule.
+ */
+var pluralRules = map[string]func(value decimal.Decimal) bool{
+	"en": isOne,
+	"nl": isOne,
+	"de": isOne,
+	"fr": func(value decimal.Decimal) bool {
+		return value.Abs().LessThan(decimal.New(2, 0))
+	},
+}
+
+func isOne(value decimal.Decimal) bool {
+	return value.Abs().Equal(decimal.New(1, 0))
+}
+
+// true if a quantity of the value takes the plural, e.g. 2 milligrams, but 1,5 gramme in French
+func (t *Translations) IsPlural(value decimal.Decimal) bool {
+	rule := pluralRules[t.Language]
+	if rule == nil {
+		rule = isOne
+	}
+	return !rule(value)
+}
+
+// formats the number with the separators of the language, e.g. 1,234.5 in English, 1.234,5 in Dutch
+func (t *Translations) FormatNumber(value decimal.Decimal) string {
+	text := value.String()
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign = "-"
+		text = text[1:]
+	}
+	integer, fraction := text, ""
+	if i := strings.Index(text, "."); i >= 0 {
+		integer, fraction = text[:i], text[i+1:]
+	}
+	var buffer bytes.Buffer
+	buffer.WriteString(sign)
+	for i, r := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			buffer.WriteString(t.GroupSeparator)
+		}
+		buffer.WriteRune(r)
+	}
+	if fraction != "" {
+		buffer.WriteString(t.DecimalSeparator)
+		buffer.WriteString(fraction)
+	}
+	return buffer.String()
+}
+
+/**
+formats a quantity, e.g. "1 milligram", "2,5 milligram" (Dutch), "2 milligrams", "2 (milligrams) / (deciliter)".
+The first unit of the numerator is in plural if the plural rule of the language says so, annotations are left out.
+ */
+func (t *Translations) FormatQuantity(model *UcumModel, value decimal.Decimal, unit string) (string, error) {
+	number := t.FormatNumber(value)
+	if unit == "" || unit == "1" {
+		return number, nil
+	}
+	term, err := NewExpressionParser(model).Parse(unit)
+	if err != nil {
+		return "", err
+	}
+	if symbol, instanceof := term.Comp.(*Symbol); instanceof && term.Term == nil && symbol.Exponent == 1 {
+		return number + " " + t.SymbolName(symbol, t.IsPlural(value)), nil
+	}
+	structure := ComposeLocalisedStructure(term, t, t.IsPlural(value))
+	if structure == "1" {
+		//only annotations, as in {cells}
+		return number, nil
+	}
+	return number + " " + structure, nil
+}
+
+/**
+searches the prefixes and units of the model on their translated names (singular and plural), case insensitive.
+Concepts whose name starts with the text come first, then the concepts whose name contains the text.
+ */
+func (t *Translations) Search(model *UcumModel, text string) []Concepter {
+	text = strings.ToLower(text)
+	type match struct {
+		concept Concepter
+		rank    int
+	}
+	matches := make([]*match, 0)
+	consider := func(concept Concepter) {
+		names := []string{t.Name(concept, false), t.Name(concept, true)}
+		rank := -1
+		for _, name := range names {
+			name = strings.ToLower(name)
+			if strings.HasPrefix(name, text) {
+				rank = 0
+			} else if rank < 0 && strings.Contains(name, text) {
+				rank = 1
+			}
+		}
+		if rank >= 0 {
+			matches = append(matches, &match{concept: concept, rank: rank})
+		}
+	}
+	for _, p := range model.Prefixes {
+		consider(p)
+	}
+	for _, b := range model.BaseUnits {
+		consider(b)
+	}
+	for _, d := range model.DefinedUnits {
+		consider(d)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].rank < matches[j].rank
+	})
+	result := make([]Concepter, 0)
+	for _, m := range matches {
+		result = append(result, m.concept)
+	}
+	return result
+}
+
+//TranslationTable=====================================================
+/**
+TranslationTable holds the translations by language, the bundled translations are English (plural names),
+Dutch, German and French. Languages are case insensitive.
+Translations may be registered while other goroutines look them up.
+ */
+type TranslationTable struct {
+	mutex        sync.RWMutex
+	translations map[string]*Translations
+}
+
+func NewTranslationTable() *TranslationTable {
+	t := &TranslationTable{}
+	t.translations = make(map[string]*Translations)
+	t.Register(englishTranslations())
+	t.Register(dutchTranslations())
+	t.Register(germanTranslations())
+	t.Register(frenchTranslations())
+	return t
+}
+
+// registers the translations, replacing the translations of the same language
+func (t *TranslationTable) Register(translations *Translations) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.translations[strings.ToLower(translations.Language)] = translations
+}
+
+// reads translations in JSON and registers them, see ReadTranslations
+func (t *TranslationTable) Load(reader io.Reader) error {
+	translations, err := ReadTranslations(reader)
+	if err != nil {
+		return err
+	}
+	t.Register(translations)
+	return nil
+}
+
+// returns the translations of the language, an error if there are none
+func (t *TranslationTable) Get(language string) (*Translations, error) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	translations := t.translations[strings.ToLower(language)]
+	if translations == nil {
+		return nil, fmt.Errorf("no translations for language " + language)
+	}
+	return translations, nil
+}
+
+func (t *TranslationTable) GetLanguages() []string {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	result := make([]string, 0)
+	for language := range t.translations {
+		result = append(result, language)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -27,28 +27,107 @@ type UcumService interface {
 	ValidateUCUM() []string
 	/**
 	 * Search through the UCUM concepts for any concept containing matching text.
-	 * Search will be limited to the kind of concept defined by kind, or all if kind
-	 * is null
+	 * Search will be limited to the kind of concept defined by kind
+	 * (use SearchConcepts to search several or all kinds)
 	 *
-	 * @param kind - can be null. scope of search
+	 * @param kind - scope of search: PREFIX, BASEUNIT or UNIT
 	 * @param text - required
 	 * @param isRegex
 	 * @return
 	 */
 	Search(kind ConceptKind, text string, isRegex bool) ([]Concepter, error)
 	/**
-	 * return a list of the defined types of units in this UCUM version
+	 * Search through the UCUM concepts for any concept containing matching text,
+	 * in the scope defined by the options: kinds, class, property, metric, special,
+	 * arbitrary and a limit on the number of results.
+	 *
+	 * @param text - empty matches every concept in scope
+	 * @param isRegex
+	 * @param options - can be nil, to search all concepts
+	 * @return
+	 */
+	SearchConcepts(text string, isRegex bool, options *SearchOptions) ([]Concepter, error)
+	/**
+	 * Search through the UCUM concepts for any concept matching the text, on code,
+	 * name, print symbol, property or class. The results are ranked: exact code,
+	 * then code or name prefix, then whole words, then fuzzy matches.
+	 *
+	 * @param text - required
+	 * @param offset - index of the first result to return
+	 * @param limit - maximum number of results to return, 0 for all
+	 * @return
+	 */
+	SearchRanked(text string, offset, limit int) ([]*SearchResult, error)
+	/**
+	 * Search through the UCUM units, including the prefixed forms of the metric units
+	 * (e.g. mg, kPa, milligram), which are made on the fly. If composite is true,
+	 * a text like "milligram per deciliter" or "mg/dL" is also found as a composite unit.
+	 * Units which are not concepts of the model are returned as SynthesizedUnit.
+	 *
+	 * @param text - required
+	 * @param composite - recognise composite units
+	 * @param offset - index of the first result to return
+	 * @param limit - maximum number of results to return, 0 for all
+	 * @return
+	 */
+	SearchUnits(text string, composite bool, offset, limit int) ([]*SearchResult, error)
+	/**
+	 * return a list of the defined types of units in this UCUM version,
+	 * without duplicates and sorted
 	 *
 	 * @return
 	 */
 	GetProperties() []string
+	/**
+	 * return the base units and defined units of a property
+	 *
+	 * @param property
+	 * @return
+	 */
+	GetUnitsForProperty(property string) []Uniter
+	/**
+	 * return a summary of a property: number of units, canonical units,
+	 * base unit (if any) and some example units
+	 *
+	 * @param property
+	 * @return
+	 */
+	GetPropertyInfo(property string) (*PropertyInfo, error)
 	/**
 	 * validate whether a unit code are valid UCUM units
+	 * A valid unit using units of an overlay is tagged as non-standard in the message.
 	 *
 	 * @param units - the unit code to check
 	 * @return nil if valid, or an error message describing the problem
 	 */
 	Validate(unit string) (bool, string)
+	/**
+	 * merge the site-local units of an overlay file into the model,
+	 * in JSON if the file name ends with .json, in XML otherwise.
+	 * The model is changed in place without locking: load overlays before the service is used
+	 * by other goroutines, and not on the shared instance of GetInstanceOfUcumEssenceService
+	 * once it is in use
+	 *
+	 * @param fileName
+	 * @return an IntegrityError if the units conflict with the model
+	 */
+	LoadOverlay(fileName string) error
+	/**
+	 * compare the model in use with another version of the essence file,
+	 * to assess the impact of an upgrade
+	 *
+	 * @param xmlFileName - the other version
+	 * @return the differences, from the model in use to the other version
+	 */
+	DiffVersion(xmlFileName string) (*ModelDiff, error)
+	/**
+	 * save the model as snapshot, to start faster with NewUcumEssenceServiceFromSnapshot,
+	 * in JSON if the file name ends with .json, in binary form otherwise
+	 *
+	 * @param fileName
+	 * @return
+	 */
+	SaveSnapshot(fileName string) error
 	/**
 	 * given a unit, return a formal description of what the units stand for using
 	 * full names
@@ -84,6 +163,7 @@ type UcumService interface {
 	GetCanonicalUnits(unit string) (string, error)
 	/**
 	 * given two pairs of units, return true if they share the same canonical base
+	 * and the same arbitrary units
 	 *
 	 * @param units1
 	 * @param units2
@@ -92,6 +172,14 @@ type UcumService interface {
 	 * @
 	 */
 	IsComparable(units1, units2 string) (bool, error)
+	/**
+	 * return true if the unit is, or contains, an arbitrary unit (e.g. [IU], [arb'U]).
+	 * Quantities in arbitrary units are only comparable to quantities in the same arbitrary units
+	 *
+	 * @param unit
+	 * @return
+	 */
+	IsArbitrary(unit string) (bool, error)
 	/**
 	 * for a given canonical unit, return all the defined units that have the
 	 * same canonical unit.
@@ -114,7 +202,9 @@ type UcumService interface {
 	GetCanonicalForm(value *Pair) (*Pair, error)
 	/**
 	 * given a value and source unit, return the value in the given dest unit
-	 * an exception is thrown if the conversion is not possible
+	 * an exception is thrown if the conversion is not possible.
+	 * The AnalyteFactorTable is not used: quantities in arbitrary units (e.g. [IU])
+	 * are only converted to other units by ConvertAnalyte
 	 *
 	 * @param value
 	 * @param sourceUnit
@@ -124,6 +214,32 @@ type UcumService interface {
 	 * @throws OHFException
 	 */
 	Convert(value decimal.Decimal, sourceUnit, destUnit string) (decimal.Decimal, error)
+	/**
+	 * given a value and source unit, return the value in the given dest unit,
+	 * also allowing conversion between mass and amount of substance
+	 * (e.g. mg/dL -> mmol/L) using the molar mass of the analyte
+	 *
+	 * @param value
+	 * @param sourceUnit
+	 * @param destUnit
+	 * @param molarMass - in g/mol
+	 * @return the value if a conversion is possible
+	 */
+	ConvertWithMolarMass(value decimal.Decimal, sourceUnit, destUnit string, molarMass decimal.Decimal) (decimal.Decimal, error)
+	/**
+	 * as ConvertWithMolarMass, but the molar mass is looked up by analyte name
+	 * in the MolarMassTable of the service. Arbitrary units (e.g. [IU]) are
+	 * converted using the analyte specific factors in the AnalyteFactorTable
+	 * of the service. This is the only conversion which uses the AnalyteFactorTable,
+	 * Convert and ConvertWithMolarMass refuse arbitrary units.
+	 *
+	 * @param value
+	 * @param sourceUnit
+	 * @param destUnit
+	 * @param analyte - e.g. "glucose"
+	 * @return the value if a conversion is possible
+	 */
+	ConvertAnalyte(value decimal.Decimal, sourceUnit, destUnit, analyte string) (decimal.Decimal, error)
 	/**
 	 * multiply two value/units pairs together and return the result in canonical units
 	 *
@@ -145,6 +261,85 @@ type UcumService interface {
 	 * @return the preferred human display form
 	 */
 	GetCommonDisplay(code string) string
+	/**
+	 * render a unit with the print symbols of its prefixes and units,
+	 * e.g. "μg/dL", "m²", "10⁹/L" in PRINT_UNICODE, "m<sup>2</sup>" in PRINT_HTML
+	 *
+	 * @param unit the unit code
+	 * @param style PRINT_UNICODE, PRINT_HTML, PRINT_LATEX or PRINT_ASCII
+	 * @return the rendered unit
+	 */
+	RenderPrintSymbol(unit string, style PrintStyle) (string, error)
+	/**
+	 * as Analyse, with the names of a language of the translation table, e.g. "(Milligramm) / (Deziliter)" in "de"
+	 *
+	 * @param unit the unit code
+	 * @param language e.g. "en", "nl", "de", "fr", or a language loaded by LoadTranslations
+	 * @return formal description
+	 */
+	AnalyseIn(unit, language string) (string, error)
+	/**
+	 * as GetCommonDisplay, with the names of a language of the translation table
+	 */
+	GetCommonDisplayIn(code, language string) string
+	/**
+	 * format a quantity in a language, with its number format and plural rules,
+	 * e.g. "2 milligrams" in "en", "2,5 Milligramm" in "de"
+	 *
+	 * @param value
+	 * @param unit the unit code
+	 * @param language
+	 * @return the formatted quantity
+	 */
+	FormatQuantity(value decimal.Decimal, unit, language string) (string, error)
+	/**
+	 * search prefixes and units on their names in a language
+	 *
+	 * @param language
+	 * @param text - part of a name, case insensitive
+	 * @return the matching concepts, names starting with the text first
+	 */
+	SearchTranslated(language, text string) ([]Concepter, error)
+	/**
+	 * add the translations of a JSON file to the translation table, see ReadTranslations
+	 *
+	 * @param fileName
+	 * @return
+	 */
+	LoadTranslations(fileName string) error
+	/**
+	 * render a unit in English words, e.g. "milligrams per deciliter", "cubic centimeter", "per microliter"
+	 *
+	 * @param unit the unit code
+	 * @param plural - true for the plural form, as in "milligrams per deciliter"
+	 * @return the unit in words
+	 */
+	RenderNatural(unit string, plural bool) (string, error)
+	/**
+	 * render a quantity in English words, e.g. "5 milligrams per kilogram per day"
+	 *
+	 * @param value
+	 * @param unit the unit code
+	 * @return the quantity in words
+	 */
+	RenderNaturalQuantity(value decimal.Decimal, unit string) (string, error)
+	/**
+	 * parse an English phrase into a UCUM expression, e.g. "milligrams per deciliter" -> "mg/dL",
+	 * see NaturalLanguageParser
+	 *
+	 * @param phrase
+	 * @return a valid UCUM expression
+	 */
+	ParseNatural(phrase string) (string, error)
+	/**
+	 * return the normal form of a unit, which keeps the atoms and prefixes: equivalent ways
+	 * of writing a unit have the same normal form, e.g. mg/dL, mg.dL-1 and /dL.mg are all mg/dL
+	 * (see NormalizeTerm), to use as key for units
+	 *
+	 * @param unit the unit code
+	 * @return the normal form
+	 */
+	NormalizeUnit(unit string) (string, error)
 
 	ListAllClasses()[]string
 	ListAllProperties()[]string
@@ -152,12 +347,28 @@ type UcumService interface {
 	SearchProperty(arg string)[]string
 	GetClassInfo(class string)*UcumClassInfo
 	FilterDefinedModels(class string, property string, onIsMetric, isMetric bool, onIsSpecial, isSpecial bool, onIsArbitrary, isArbitrary bool)[]*DefinedUnit
+	/**
+	 * return the defined units selected by the predicate, see DefinedUnitPredicate
+	 *
+	 * @param predicate
+	 * @return
+	 */
+	FilterDefinedUnits(predicate DefinedUnitPredicate) []*DefinedUnit
+	/**
+	 * return the defined units selected by the query,
+	 * e.g. "class:clinical AND metric:yes AND dim:M.L-3", see DefinedUnitQueryParser
+	 *
+	 * @param query
+	 * @return
+	 */
+	QueryDefinedUnits(query string) ([]*DefinedUnit, error)
 }
 
 // UcumVersionDetails======================================================
 type UcumVersionDetails struct {
 	ReleaseDate time.Time
 	Version     string
+	Revision    int
 }
 
 func NewUcumVersionDetails(releaseDate time.Time, version string) *UcumVersionDetails {
@@ -171,30 +382,38 @@ func NewUcumVersionDetails(releaseDate time.Time, version string) *UcumVersionDe
 const UCUM_OID = "2.16.840.1.113883.6.8"
 
 type UcumEssenceService struct {
-	Model    *UcumModel
-	Handlers *Registry
+	Model          *UcumModel
+	Handlers       *Registry
+	MolarMasses    *MolarMassTable
+	AnalyteFactors *AnalyteFactorTable
+	Translations   *TranslationTable
+	//decides which dimensionless quantities IsComparable and Convert accept as comparable
+	DimensionlessPolicy DimensionlessPolicy
 }
 
 func (u *UcumEssenceService)FilterDefinedModels(class string, property string, onIsMetric, isMetric bool, onIsSpecial, isSpecial bool, onIsArbitrary, isArbitrary bool)[]*DefinedUnit{
-	duf := NewDefinedUnitFilter(u.Model.DefinedUnits);
-	if class != "" {
-		duf = duf.FilterByClass(class)
-	}
-	if property != "" {
-		duf = duf.FilterByProperty(property)
-	}
-	if onIsMetric {
-		duf = duf.FilterByIsMetric(isMetric)
-	}
-	if onIsSpecial {
-		duf = duf.FilterByIsSpecial(isSpecial)
-	}
-	if onIsArbitrary {
-		duf = duf.FilterByIsArbitrary(isArbitrary)
-	}
+	options := NewSearchOptions(UNIT)
+	options.Class = class
+	options.Property = property
+	options.OnIsMetric, options.IsMetric = onIsMetric, isMetric
+	options.OnIsSpecial, options.IsSpecial = onIsSpecial, isSpecial
+	options.OnIsArbitrary, options.IsArbitrary = onIsArbitrary, isArbitrary
+	duf := options.Filter(NewDefinedUnitFilter(u.Model.DefinedUnits))
 	return duf.DefinedUnits
 }
 
+func (u *UcumEssenceService) FilterDefinedUnits(predicate DefinedUnitPredicate) []*DefinedUnit {
+	return NewDefinedUnitFilter(u.Model.DefinedUnits).Filter(predicate).DefinedUnits
+}
+
+func (u *UcumEssenceService) QueryDefinedUnits(query string) ([]*DefinedUnit, error) {
+	predicate, err := NewDefinedUnitQueryParser(u.Model, u.Handlers).Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return u.FilterDefinedUnits(predicate), nil
+}
+
 func (u *UcumEssenceService) ListAllClasses()[]string{
 	return u.Model.ClassList
 }
@@ -219,25 +438,69 @@ var instanceOfUcumEssenceService *UcumEssenceService
 
 func GetInstanceOfUcumEssenceService(xmlFileName string) (*UcumEssenceService, error) {
 	if instanceOfUcumEssenceService == nil {
-		instanceOfUcumEssenceService = new(UcumEssenceService)
-		xmlFile, err := os.Open(xmlFileName)
-		if err != nil {
-			return nil, err
-		}
-		defer xmlFile.Close()
-		d := new(DefinitionParser)
-		instanceOfUcumEssenceService.Model, err = d.UnmarshalTerminology(xmlFile)
+		service, err := NewUcumEssenceService(xmlFileName, new(DefinitionParser))
 		if err != nil {
 			return nil, err
 		}
+		instanceOfUcumEssenceService = service
 	}
 	return instanceOfUcumEssenceService, nil
 }
 
+/**
+NewUcumEssenceServiceFromSnapshot loads a snapshot saved by SaveSnapshot, in JSON if the file name ends
+with .json, in binary form otherwise. expectedVersion is the UCUM version the snapshot must have,
+or empty to accept any version.
+ */
+func NewUcumEssenceServiceFromSnapshot(fileName, expectedVersion string) (*UcumEssenceService, error) {
+	u := new(UcumEssenceService)
+	u.Handlers = NewRegistry()
+	u.MolarMasses = NewMolarMassTable()
+	u.AnalyteFactors = NewAnalyteFactorTable()
+	u.Translations = NewTranslationTable()
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if strings.HasSuffix(strings.ToLower(fileName), ".json") {
+		u.Model, err = ReadModelJSON(file, expectedVersion)
+	} else {
+		u.Model, err = ReadModelBinary(file, expectedVersion)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+/**
+NewUcumEssenceService loads the essence file with the parser, e.g. NewStrictDefinitionParser(true)
+to fail on a corrupted file. Unlike GetInstanceOfUcumEssenceService, it returns a new service every time.
+ */
+func NewUcumEssenceService(xmlFileName string, parser *DefinitionParser) (*UcumEssenceService, error) {
+	u := new(UcumEssenceService)
+	u.MolarMasses = NewMolarMassTable()
+	u.AnalyteFactors = NewAnalyteFactorTable()
+	u.Translations = NewTranslationTable()
+	u.Handlers = parser.Handlers
+	xmlFile, err := os.Open(xmlFileName)
+	if err != nil {
+		return nil, err
+	}
+	defer xmlFile.Close()
+	u.Model, err = parser.UnmarshalTerminology(xmlFile)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
 func (u *UcumEssenceService) UcumIdentification() *UcumVersionDetails {
 	d := &UcumVersionDetails{}
 	d.ReleaseDate = u.Model.RevisionDate
 	d.Version = u.Model.Version
+	d.Revision = u.Model.RevisionNumber
 	return d
 }
 
@@ -249,37 +512,137 @@ func (u *UcumEssenceService) Search(kind ConceptKind, text string, isRegex bool)
 	if text == "" {
 		return nil, fmt.Errorf("search text must not be empty")
 	}
-	return u.Model.Search(kind, text, isRegex), nil
+	return u.Model.Search(kind, text, isRegex)
+}
+
+func (u *UcumEssenceService) SearchConcepts(text string, isRegex bool, options *SearchOptions) ([]Concepter, error) {
+	return u.Model.SearchWithOptions(text, isRegex, options)
+}
+
+func (u *UcumEssenceService) SearchRanked(text string, offset, limit int) ([]*SearchResult, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("search text must not be empty")
+	}
+	return u.Model.SearchIndex.Search(text, offset, limit), nil
+}
+
+func (u *UcumEssenceService) SearchUnits(text string, composite bool, offset, limit int) ([]*SearchResult, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("search text must not be empty")
+	}
+	return u.Model.SearchIndex.SearchUnits(text, composite, offset, limit), nil
 }
 
 func (u *UcumEssenceService) GetProperties() []string {
-	result := make([]string, 0)
-	for _, unit := range u.Model.DefinedUnits {
-		result = append(result, unit.GetProperty())
+	return u.ListAllProperties()
+}
+
+func (u *UcumEssenceService) GetUnitsForProperty(property string) []Uniter {
+	return u.Model.GetUnitsForProperty(property)
+}
+
+const MAX_EXAMPLE_UNITS = 5
+
+func (u *UcumEssenceService) GetPropertyInfo(property string) (*PropertyInfo, error) {
+	units := u.Model.GetUnitsForProperty(property)
+	if len(units) == 0 {
+		return nil, fmt.Errorf("GetPropertyInfo: unknown property " + property)
 	}
-	return result
+	info := &PropertyInfo{}
+	info.Name = property
+	info.UnitCount = len(units)
+	info.ExampleUnits = make([]string, 0)
+	for _, unit := range units {
+		if bu, instanceof := unit.(*BaseUnit); instanceof {
+			info.BaseUnit = bu
+		}
+		if len(info.ExampleUnits) < MAX_EXAMPLE_UNITS {
+			info.ExampleUnits = append(info.ExampleUnits, unit.GetCode())
+		}
+		//special and arbitrary units do not tell the dimension of the property
+		if du, instanceof := unit.(*DefinedUnit); instanceof && (du.IsSpecial || du.IsArbitrary) {
+			continue
+		}
+		if info.Canonical == "" {
+			info.Canonical, _ = u.GetCanonicalUnits(unit.GetCode())
+		}
+	}
+	return info, nil
 }
 
 func (u *UcumEssenceService) Validate(unit string) (bool, string) {
 	if unit == "" {
 		return true, "search text must not be empty"
 	}
-	_, err := NewExpressionParser(u.Model).Parse(unit)
+	term, err := NewExpressionParser(u.Model).Parse(unit)
 	if err != nil {
 		return false, err.Error()
 	}
+	local := make([]string, 0)
+	for _, lu := range localUnitsOf(term) {
+		local = append(local, lu.Code+" ("+lu.Provenance+")")
+	}
+	if len(local) > 0 {
+		return true, "non-standard: " + strings.Join(local, ", ")
+	}
 	return true, ""
 }
 
-func (u *UcumEssenceService) Analyse(unit string) (string, error) {
-	if unit == "" {
-		return "(unity)", nil
+// returns the units of the term which were added by an overlay
+func localUnitsOf(term *Term) []*DefinedUnit {
+	result := make([]*DefinedUnit, 0)
+	for t := term; t != nil; t = t.Term {
+		switch comp := t.Comp.(type) {
+		case *Term:
+			result = append(result, localUnitsOf(comp)...)
+		case *Symbol:
+			if du, instanceof := comp.Unit.(*DefinedUnit); instanceof && du.IsLocal() {
+				result = append(result, du)
+			}
+		}
 	}
-	term, err := NewExpressionParser(u.Model).Parse(unit)
+	return result
+}
+
+func (u *UcumEssenceService) SaveSnapshot(fileName string) error {
+	file, err := os.Create(fileName)
 	if err != nil {
-		return "", err
+		return err
+	}
+	if strings.HasSuffix(strings.ToLower(fileName), ".json") {
+		err = WriteModelJSON(u.Model, u.Handlers, file)
+	} else {
+		err = WriteModelBinary(u.Model, u.Handlers, file)
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (u *UcumEssenceService) DiffVersion(xmlFileName string) (*ModelDiff, error) {
+	other, err := NewUcumEssenceService(xmlFileName, new(DefinitionParser))
+	if err != nil {
+		return nil, err
 	}
-	return ComposeFormalStructure(term), nil
+	return DiffModels(u.Model, other.Model, u.Handlers), nil
+}
+
+func (u *UcumEssenceService) LoadOverlay(fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if strings.HasSuffix(strings.ToLower(fileName), ".json") {
+		return new(DefinitionParser).MergeOverlayJSON(u.Model, file)
+	}
+	return new(DefinitionParser).MergeOverlay(u.Model, file)
+}
+
+func (u *UcumEssenceService) Analyse(unit string) (string, error) {
+	return GenerateDisplay(u.Model, unit)
 }
 
 func (u *UcumEssenceService) ValidateInProperty(unit, property string) string {
@@ -356,15 +719,34 @@ func (u *UcumEssenceService) IsComparable(units1, units2 string) (bool, error) {
 	if units2 == "" {
 		return false, nil
 	}
-	u1, err := u.GetCanonicalUnits(units1)
+	c1, err := u.getCanonical(units1)
+	if err != nil {
+		return false, err
+	}
+	c2, err := u.getCanonical(units2)
 	if err != nil {
 		return false, err
 	}
-	u2, err := u.GetCanonicalUnits(units2)
+	return u.checkComparable(units1, units2, c1, c2) == nil, nil
+}
+
+func (u *UcumEssenceService) IsArbitrary(unit string) (bool, error) {
+	if unit == "" {
+		return false, fmt.Errorf("IsArbitrary: unit must not be null or empty")
+	}
+	can, err := u.getCanonical(unit)
 	if err != nil {
 		return false, err
 	}
-	return u1 == u2, nil
+	return can.IsArbitrary(), nil
+}
+
+func (u *UcumEssenceService) getCanonical(unit string) (*Canonical, error) {
+	term, err := NewExpressionParser(u.Model).Parse(unit)
+	if err != nil {
+		return nil, err
+	}
+	return NewConverter(u.Model, u.Handlers).Convert(term)
 }
 
 func (u *UcumEssenceService) GetDefinedForms(code string) ([]*DefinedUnit, error) {
@@ -443,27 +825,314 @@ func (u *UcumEssenceService) Convert(value decimal.Decimal, sourceUnit, destUnit
 	if err != nil {
 		return decimal.Decimal{}, err
 	}
-	s := ComposeExpression(src, false)
-	d := ComposeExpression(dst, false)
-	if s != d {
-		return decimal.Decimal{}, fmt.Errorf("Unable to convert between units " + sourceUnit + " and " + destUnit + " as they do not have matching canonical forms (" + s + " and " + d + " respectively)")
+	err = u.checkComparable(sourceUnit, destUnit, src, dst)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	if dst.Value.Sign() == 0 {
+		return decimal.Decimal{}, fmt.Errorf("Convert: the unit " + destUnit + " has no scale (special units are not supported)")
 	}
 	canValue := value.Mul(src.Value)
 	dr := canValue.Div(dst.Value)
 	return dr, nil
 }
 
+func (u *UcumEssenceService) ConvertWithMolarMass(value decimal.Decimal, sourceUnit, destUnit string, molarMass decimal.Decimal) (decimal.Decimal, error) {
+	if molarMass.Sign() <= 0 {
+		return decimal.Decimal{}, fmt.Errorf("ConvertWithMolarMass: molarMass must be greater than zero")
+	}
+	return u.convertAnalyte(value, sourceUnit, destUnit, nil, molarMass)
+}
+
+func (u *UcumEssenceService) ConvertAnalyte(value decimal.Decimal, sourceUnit, destUnit, analyte string) (decimal.Decimal, error) {
+	if analyte == "" {
+		return decimal.Decimal{}, fmt.Errorf("ConvertAnalyte: analyte must not be empty")
+	}
+	if !u.MolarMasses.Exists(analyte) && !u.AnalyteFactors.Exists(analyte) {
+		return decimal.Decimal{}, fmt.Errorf("ConvertAnalyte: no molar mass or factors known for analyte " + analyte)
+	}
+	return u.convertAnalyte(value, sourceUnit, destUnit, u.AnalyteFactors.Get(analyte), u.MolarMasses.Get(analyte))
+}
+
+// converts using the analyte specific definitions of arbitrary units in overrides (if any),
+// and the molar mass to go between mass and amount of substance (if not zero)
+func (u *UcumEssenceService) convertAnalyte(value decimal.Decimal, sourceUnit, destUnit string, overrides map[string]*Value, molarMass decimal.Decimal) (decimal.Decimal, error) {
+	if value == decimal.Zero {
+		return decimal.Decimal{}, fmt.Errorf("Convert: value must not nil")
+	}
+	if sourceUnit == "" {
+		return decimal.Decimal{}, fmt.Errorf("Convert: sourceUnit must not be empty")
+	}
+	if destUnit == "" {
+		return decimal.Decimal{}, fmt.Errorf("Convert: destUnit must not be empty")
+	}
+	if sourceUnit == destUnit {
+		return value, nil
+	}
+	srcEp, err := NewExpressionParser(u.Model).Parse(sourceUnit)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	drcEp, err := NewExpressionParser(u.Model).Parse(destUnit)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	src, dst, err := u.analyteCanonicals(srcEp, drcEp, overrides, false)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	err = u.checkComparable(sourceUnit, destUnit, src, dst)
+	if err == nil {
+		//when both sides hold the same power of the mole, the Avogadro number cancels out
+		if src.entities["mol"] == dst.entities["mol"] {
+			if src, dst, err = u.analyteCanonicals(srcEp, drcEp, overrides, true); err != nil {
+				return decimal.Decimal{}, err
+			}
+		}
+		if dst.Value.Sign() == 0 {
+			return decimal.Decimal{}, fmt.Errorf("Convert: the unit " + destUnit + " has no scale (special units are not supported)")
+		}
+		return checkUnderflow(value.Mul(src.Value).Div(dst.Value), sourceUnit, destUnit)
+	}
+	if _, instanceof := err.(*ArbitraryUnitError); instanceof || molarMass.Sign() <= 0 {
+		return decimal.Decimal{}, err
+	}
+	//the mole is defined as a pure number (6.0221367e23), so mass and amount of substance
+	//only differ by one gram in their canonical forms, and the mole is tracked as entity:
+	//the gram must be traded for the mole, e.g. g -> 1 or mg -> g2 is not a molar conversion
+	s := ComposeExpression(src, false)
+	d := ComposeExpression(dst, false)
+	if ComposeExpression(src.WithoutUnit("g"), false) != ComposeExpression(dst.WithoutUnit("g"), false) {
+		return decimal.Decimal{}, err
+	}
+	grams := src.GetExponent("g") - dst.GetExponent("g")
+	if grams == 0 || dst.entities["mol"]-src.entities["mol"] != grams {
+		return decimal.Decimal{}, err
+	}
+	if grams != 1 && grams != -1 {
+		return decimal.Decimal{}, fmt.Errorf("Unable to convert between units " + sourceUnit + " and " + destUnit + " using a molar mass (" + s + " and " + d + " respectively)")
+	}
+	//with the mole as 1, one mole of the analyte weighs molarMass gram
+	if src, dst, err = u.analyteCanonicals(srcEp, drcEp, overrides, true); err != nil {
+		return decimal.Decimal{}, err
+	}
+	if dst.Value.Sign() == 0 {
+		return decimal.Decimal{}, fmt.Errorf("Convert: the unit " + destUnit + " has no scale (special units are not supported)")
+	}
+	canValue := value.Mul(src.Value)
+	if grams == 1 {
+		//mass -> amount of substance
+		canValue = canValue.Div(molarMass)
+	} else {
+		//amount of substance -> mass
+		canValue = canValue.Mul(molarMass)
+	}
+	return checkUnderflow(canValue.Div(dst.Value), sourceUnit, destUnit)
+}
+
+// converts source and destination to their canonical forms using the overrides. When moleAsOne
+// is set, the mole counts as 1 instead of 6.0221367e23, so a mole in a denominator (e.g. mg/mmol)
+// does not lose its scale to the division precision
+func (u *UcumEssenceService) analyteCanonicals(srcEp, drcEp *Term, overrides map[string]*Value, moleAsOne bool) (*Canonical, *Canonical, error) {
+	converter := NewConverter(u.Model, u.Handlers)
+	converter.Overrides = overrides
+	if moleAsOne {
+		converter.Overrides = map[string]*Value{}
+		for code, override := range overrides {
+			converter.Overrides[code] = override
+		}
+		converter.Overrides["mol"], _ = NewValue("1", "1", decimal.New(1, 0))
+	}
+	src, err := converter.Convert(srcEp)
+	if err != nil {
+		return nil, nil, err
+	}
+	dst, err := converter.Convert(drcEp)
+	if err != nil {
+		return nil, nil, err
+	}
+	return src, dst, nil
+}
+
+// returns an error if the converted value is zero, the value was too small for the division precision
+func checkUnderflow(result decimal.Decimal, sourceUnit, destUnit string) (decimal.Decimal, error) {
+	if result.Sign() == 0 {
+		return decimal.Decimal{}, fmt.Errorf("Convert: the conversion from " + sourceUnit + " to " + destUnit + " underflows to zero")
+	}
+	return result, nil
+}
+
+// returns an error if quantities in the canonical forms can not be converted into each other,
+// taking the arbitrary units and the DimensionlessPolicy into account
+func (u *UcumEssenceService) checkComparable(sourceUnit, destUnit string, src, dst *Canonical) error {
+	if !src.HasSameArbitraryUnits(dst) {
+		return NewArbitraryUnitError(sourceUnit, destUnit, src, dst)
+	}
+	if u.DimensionlessPolicy == LENIENT_DIMENSIONLESS {
+		src = src.WithoutUnit("rad")
+		dst = dst.WithoutUnit("rad")
+	}
+	s := ComposeExpression(src, false)
+	d := ComposeExpression(dst, false)
+	if s != d {
+		return fmt.Errorf("Unable to convert between units " + sourceUnit + " and " + destUnit + " as they do not have matching canonical forms (" + s + " and " + d + " respectively)")
+	}
+	if u.DimensionlessPolicy == RATIO_KIND_AWARE && src.Dimensionless && !src.HasSameRatioKind(dst) {
+		return fmt.Errorf("Unable to convert between units " + sourceUnit + " and " + destUnit + " as they are ratios of different kinds (" + strings.Join(src.RatioKinds, ", ") + " and " + strings.Join(dst.RatioKinds, ", ") + " respectively)")
+	}
+	return nil
+}
+
+// ArbitraryUnitError=======================================================
+/**
+Returned when two quantities can not be converted or compared since they are expressed in
+different arbitrary units. Arbitrary units (e.g. [IU], [arb'U]) are not comparable by design,
+even though their canonical forms may match.
+ */
+type ArbitraryUnitError struct {
+	SourceUnit           string
+	DestUnit             string
+	SourceArbitraryUnits []string
+	DestArbitraryUnits   []string
+}
+
+func NewArbitraryUnitError(sourceUnit, destUnit string, src, dst *Canonical) *ArbitraryUnitError {
+	e := &ArbitraryUnitError{}
+	e.SourceUnit = sourceUnit
+	e.DestUnit = destUnit
+	e.SourceArbitraryUnits = src.GetArbitraryUnitCodes()
+	e.DestArbitraryUnits = dst.GetArbitraryUnitCodes()
+	return e
+}
+
+func (e *ArbitraryUnitError) Error() string {
+	describe := func(codes []string) string {
+		if len(codes) == 0 {
+			return "none"
+		}
+		return strings.Join(codes, ", ")
+	}
+	return "Unable to convert between units " + e.SourceUnit + " and " + e.DestUnit + " as they are expressed in different arbitrary units (" + describe(e.SourceArbitraryUnits) + " and " + describe(e.DestArbitraryUnits) + " respectively), which can only be converted using analyte specific factors"
+}
+
 func (u *UcumEssenceService) Multiply(o1, o2 *Pair) (*Pair, error) {
 	res := NewPair(o1.Value.Mul(o2.Value), o1.Code+"."+o2.Code)
 	return u.GetCanonicalForm(res)
 }
 
+// returns the display generated from the parse tree (see GenerateDisplay), or the code without [] if it does not parse
 func (u *UcumEssenceService) GetCommonDisplay(code string) string {
+	if display, err := GenerateDisplay(u.Model, code); err == nil {
+		return display
+	}
 	code = strings.Replace(code, "[", "", -1)
 	code = strings.Replace(code, "]", "", -1)
 	return code
 }
 
+func (u *UcumEssenceService) RenderPrintSymbol(unit string, style PrintStyle) (string, error) {
+	term, err := NewExpressionParser(u.Model).Parse(unit)
+	if err != nil {
+		return "", err
+	}
+	return NewPrintSymbolRenderer(style).Render(term), nil
+}
+
+func (u *UcumEssenceService) translations(language string) (*Translations, error) {
+	return u.Translations.Get(language)
+}
+
+func (u *UcumEssenceService) AnalyseIn(unit, language string) (string, error) {
+	translations, err := u.translations(language)
+	if err != nil {
+		return "", err
+	}
+	return GenerateLocalisedDisplay(u.Model, unit, translations)
+}
+
+func (u *UcumEssenceService) GetCommonDisplayIn(code, language string) string {
+	if display, err := u.AnalyseIn(code, language); err == nil {
+		return display
+	}
+	return u.GetCommonDisplay(code)
+}
+
+func (u *UcumEssenceService) FormatQuantity(value decimal.Decimal, unit, language string) (string, error) {
+	translations, err := u.translations(language)
+	if err != nil {
+		return "", err
+	}
+	return translations.FormatQuantity(u.Model, value, unit)
+}
+
+func (u *UcumEssenceService) SearchTranslated(language, text string) ([]Concepter, error) {
+	translations, err := u.translations(language)
+	if err != nil {
+		return nil, err
+	}
+	return translations.Search(u.Model, text), nil
+}
+
+func (u *UcumEssenceService) LoadTranslations(fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return u.Translations.Load(file)
+}
+
+func (u *UcumEssenceService) naturalLanguageRenderer(unit string) (*NaturalLanguageRenderer, *Term, error) {
+	translations, err := u.translations("en")
+	if err != nil {
+		return nil, nil, err
+	}
+	term, err := NewExpressionParser(u.Model).Parse(unit)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewNaturalLanguageRenderer(translations), term, nil
+}
+
+func (u *UcumEssenceService) RenderNatural(unit string, plural bool) (string, error) {
+	renderer, term, err := u.naturalLanguageRenderer(unit)
+	if err != nil {
+		return "", err
+	}
+	return renderer.Render(term, plural), nil
+}
+
+func (u *UcumEssenceService) RenderNaturalQuantity(value decimal.Decimal, unit string) (string, error) {
+	renderer, term, err := u.naturalLanguageRenderer(unit)
+	if err != nil {
+		return "", err
+	}
+	return renderer.RenderQuantity(value, term), nil
+}
+
+func (u *UcumEssenceService) ParseNatural(phrase string) (string, error) {
+	return u.Model.naturalParser.Parse(phrase)
+}
+
+func (u *UcumEssenceService) NormalizeUnit(unit string) (string, error) {
+	return NormalizeUnit(u.Model, unit)
+}
+
+/**
+returns the unit with the Unicode look-alikes of ASCII codes replaced, e.g. µg -> ug, ×10⁹/L -> 10*9/L,
+and the replacements made. Returns an error if the unit is not valid after the replacements.
+ */
+func (u *UcumEssenceService) ReplaceLookalikes(unit string) (string, []*Lookalike, error) {
+	code, replacements, err := ReplaceLookalikes(unit)
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := NewExpressionParser(u.Model).Parse(code); err != nil {
+		return "", nil, err
+	}
+	return code, replacements, nil
+}
+
 //UcumEssenceService=======================================================
 type UcumValidator struct {
 	Model    *UcumModel
@@ -513,8 +1182,9 @@ func (v *UcumValidator) checkUnitCode(code string, primary bool) {
 		v.Result = append(v.Result, err.Error())
 		return
 	}
+	//the composed expression may be written differently (m+2 -> m2), but must parse to the same structure
 	c := ComposeExpression(term, false)
-	if c != code {
+	if reparsed, err := NewExpressionParser(v.Model).Parse(c); err != nil || !EqualTerms(term, reparsed) {
 		v.Result = append(v.Result, "Round trip failed: "+code+" -> "+c)
 	}
 	NewConverter(v.Model, v.Handlers).Convert(term)
@@ -4,14 +4,22 @@ package ucum
 import (
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"github.com/bertverhees/ucum/decimal"
 )
 
+/**
+Version = version of UCUM, e.g. "2.1", compare with CompareVersions
+Revision = revision of the essence file as found, e.g. "$Revision: 442 $"
+RevisionNumber = the revision as number, e.g. 442, 0 if unknown
+RevisionDate = zero if unknown
+ */
 type UcumModel struct {
 	Version               	string
 	Revision              	string
+	RevisionNumber        	int
 	RevisionDate          	time.Time
 	Prefixes              	[]*Prefix
 	BaseUnits             	[]*BaseUnit
@@ -25,12 +33,19 @@ type UcumModel struct {
 	PropertyList			[]string
 	ClassSearchIndex 		map[string][]string
 	ClassList				[]string
+	SearchIndex				*SearchIndex
+	//canonical forms of the defined units, computed with canonicalHandlers, only in a model read from a snapshot
+	canonicals				map[string]*Canonical
+	canonicalHandlers		*Registry
+	//parses English phrases with the names of the model, built with the SearchIndex
+	naturalParser			*NaturalLanguageParser
 }
 
 func NewUcumModel(version, revision string, revisionDate time.Time) *UcumModel {
 	r := &UcumModel{}
 	r.Version = version
 	r.Revision = revision
+	r.RevisionNumber, _ = ParseRevision(revision)
 	r.RevisionDate = revisionDate
 	r.Prefixes = make([]*Prefix, 0)
 	r.BaseUnits = make([]*BaseUnit, 0)
@@ -42,6 +57,64 @@ func NewUcumModel(version, revision string, revisionDate time.Time) *UcumModel {
 	return r
 }
 
+// adds the property to the PropertyList and PropertySearchIndex, if not yet known
+func (u *UcumModel) addProperty(property string) {
+	for _, s := range u.PropertyList {
+		if s == property {
+			return
+		}
+	}
+	u.PropertyList = append(u.PropertyList, property)
+	addSearchToIndex(u.PropertySearchIndex, property)
+}
+
+// computes the canonical forms of the defined units with the handlers, units which can not be converted are left out
+func (u *UcumModel) computeCanonicals(handlers *Registry) map[string]*Canonical {
+	canonicals := make(map[string]*Canonical)
+	converter := NewConverter(u, handlers)
+	for _, unit := range u.DefinedUnits {
+		can, err := converter.expandDefinedUnit("", unit)
+		if err == nil {
+			canonicals[unit.Code] = can
+		}
+	}
+	return canonicals
+}
+
+// adds the unit, with its property and class
+func (u *UcumModel) addDefinedUnit(unit *DefinedUnit) {
+	u.addProperty(unit.Property)
+	found := false
+	for _,s := range u.ClassList{
+		if s == unit.Class {
+			found = true
+			break
+		}
+	}
+	if !found {
+		u.ClassList = append(u.ClassList,unit.Class)
+		addSearchToIndex(u.ClassSearchIndex, unit.Class)
+	}
+	u.DefinedUnits = append(u.DefinedUnits, unit)
+	u.DefinedUnitsByCode[unit.Code] = unit
+}
+
+// returns the base units and defined units of the property
+func (u *UcumModel) GetUnitsForProperty(property string) []Uniter {
+	result := make([]Uniter, 0)
+	for _, unit := range u.BaseUnits {
+		if unit.Property == property {
+			result = append(result, unit)
+		}
+	}
+	for _, unit := range u.DefinedUnits {
+		if unit.Property == property {
+			result = append(result, unit)
+		}
+	}
+	return result
+}
+
 func (u *UcumModel) GetUnit(code string) Uniter {
 	r1 := u.BaseUnitsByCode[code]
 	if r1 != nil {
@@ -54,21 +127,44 @@ func (u *UcumModel) GetUnit(code string) Uniter {
 	return nil
 }
 
-func (u *UcumModel) Search(kind ConceptKind, text string, isRegex bool) []Concepter {
+// searches the concepts of one kind, PREFIX, BASEUNIT or UNIT
+func (u *UcumModel) Search(kind ConceptKind, text string, isRegex bool) ([]Concepter, error) {
+	return u.SearchWithOptions(text, isRegex, NewSearchOptions(kind))
+}
+
+// searches the concepts in the scope of the options, all concepts if options is nil
+func (u *UcumModel) SearchWithOptions(text string, isRegex bool, options *SearchOptions) ([]Concepter, error) {
+	var re *regexp.Regexp
+	if isRegex {
+		var err error
+		re, err = regexp.Compile(text)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if options == nil {
+		options = NewSearchOptions()
+	}
 	concepts := make([]Concepter, 0)
-	if kind == 0 || kind == PREFIX {
-		concepts = append(concepts, u.searchPrefixes(text, isRegex)...)
+	if options.HasKind(PREFIX) && !options.filtersUnits() {
+		concepts = append(concepts, u.searchPrefixes(text, re)...)
 	}
-	if kind == 0 || kind == BASEUNIT || kind == UNIT {
-		concepts = append(concepts, u.searchUnits(text, isRegex, kind)...)
+	if options.HasKind(BASEUNIT) {
+		concepts = append(concepts, u.searchBaseUnits(text, re, options)...)
 	}
-	return concepts
+	if options.HasKind(UNIT) {
+		concepts = append(concepts, u.searchDefinedUnits(text, re, options)...)
+	}
+	if options.Limit > 0 && options.Limit < len(concepts) {
+		concepts = concepts[:options.Limit]
+	}
+	return concepts, nil
 }
 
-func (u *UcumModel) searchPrefixes(text string, isRegex bool) []Concepter {
+func (u *UcumModel) searchPrefixes(text string, re *regexp.Regexp) []Concepter {
 	concepts := make([]Concepter, 0)
 	for _, c := range u.Prefixes {
-		if u.matchesConcept(c, text, isRegex) {
+		if u.matchesConcept(c, text, re) {
 			concepts = append(concepts, c)
 		}
 	}
@@ -79,51 +175,52 @@ func (u *UcumModel) getBaseUnit(code string) *BaseUnit {
 	return u.BaseUnitsByCode[code]
 }
 
-func (u *UcumModel) searchUnits(text string, isRegex bool, kind ConceptKind) []Concepter {
+func (u *UcumModel) searchBaseUnits(text string, re *regexp.Regexp, options *SearchOptions) []Concepter {
 	concepts := make([]Concepter, 0)
-	if kind == BASEUNIT {
-		for _, unit := range u.BaseUnits {
-			if u.matchesUnit(unit, text, isRegex) {
-				concepts = append(concepts, unit)
-			}
+	for _, unit := range u.BaseUnits {
+		if options.acceptsBaseUnit(unit) && u.matchesUnit(unit, text, re) {
+			concepts = append(concepts, unit)
 		}
 	}
-	if kind == UNIT {
-		for _, unit := range u.DefinedUnits {
-			if u.matchesUnit(unit, text, isRegex) {
-				concepts = append(concepts, unit)
-			}
+	return concepts
+}
+
+func (u *UcumModel) searchDefinedUnits(text string, re *regexp.Regexp, options *SearchOptions) []Concepter {
+	concepts := make([]Concepter, 0)
+	for _, unit := range options.Filter(NewDefinedUnitFilter(u.DefinedUnits)).DefinedUnits {
+		if u.matchesUnit(unit, text, re) {
+			concepts = append(concepts, unit)
 		}
 	}
 	return concepts
 }
 
-func (u *UcumModel) matchesUnit(unit Uniter, text string, isRegex bool) bool {
-	return u.matches(unit.GetProperty(), text, isRegex) || u.matchesConcept(unit, text, isRegex)
+func (u *UcumModel) matchesUnit(unit Uniter, text string, re *regexp.Regexp) bool {
+	return u.matches(unit.GetProperty(), text, re) || u.matchesConcept(unit, text, re)
 }
 
-func (u *UcumModel) matches(value, text string, isRegEx bool) bool {
-	if isRegEx {
-		b, _ := regexp.MatchString( text, value)
-		return b
+// re is the compiled text if searching with a regular expression, nil otherwise
+func (u *UcumModel) matches(value, text string, re *regexp.Regexp) bool {
+	if re != nil {
+		return re.MatchString(value)
 	} else {
 		return strings.Contains(strings.ToLower(value), strings.ToLower(text))
 	}
 }
 
-func (u *UcumModel) matchesConcept(concept Concepter, text string, isRegex bool) bool {
+func (u *UcumModel) matchesConcept(concept Concepter, text string, re *regexp.Regexp) bool {
 	for _, name := range concept.GetNames() {
-		if u.matches(name, text, isRegex) {
+		if u.matches(name, text, re) {
 			return true
 		}
 	}
-	if u.matches(concept.GetCode(), text, isRegex) {
+	if u.matches(concept.GetCode(), text, re) {
 		return true
 	}
-	if u.matches(concept.GetCodeUC(), text, isRegex) {
+	if u.matches(concept.GetCodeUC(), text, re) {
 		return true
 	}
-	if u.matches(concept.GetPrintSymbol(), text, isRegex) {
+	if u.matches(concept.GetPrintSymbol(), text, re) {
 		return true
 	}
 	return false
@@ -134,6 +231,21 @@ type UcumClassInfo struct {
 	Description string
 }
 
+/**
+Summary of a property (the kind of quantity a unit measures, e.g. "length", "mass concentration").
+UnitCount = number of base units and defined units of the property
+Canonical = canonical units of the property, e.g. "g.m-3" for "mass concentration"
+BaseUnit = the base unit of the property, nil if it has none
+ExampleUnits = codes of some units of the property, the base unit first
+ */
+type PropertyInfo struct {
+	Name         string
+	UnitCount    int
+	Canonical    string
+	BaseUnit     *BaseUnit
+	ExampleUnits []string
+}
+
 // Concept=====================================================
 /**
 Hierarchy
@@ -149,8 +261,9 @@ Top class
 Code = String (case sensitive c/s)
 CodeUC = String, case insensitive c/i)
 Kind = ConceptKind (PREFIX, BASEUNIT or UNIT)
-Name = full (official) name of the concept
-PrintSymbol
+Names = full (official) name of the concept, followed by the alternate names
+PrintSymbol = print symbol, with superscripts and subscripts in Unicode characters where possible
+Provenance = source of a concept added by an overlay (see MergeOverlay), empty for the official essence file
  */
 type Concepter interface {
 	GetDescription() string
@@ -162,12 +275,23 @@ type Concepter interface {
 	GetPrintSymbol() string
 }
 
+/**
+MarkupConcepter is a Concepter with the markup of its print symbol, as in the essence file,
+nil if the concept has none. Prefixes and units of a model implement it.
+ */
+type MarkupConcepter interface {
+	Concepter
+	GetPrintSymbolMarkup() *Markup
+}
+
 type Concept struct {
-	Code        string
-	CodeUC      string
-	Kind        ConceptKind
-	Names       []string
-	PrintSymbol string
+	Code              string
+	CodeUC            string
+	Kind              ConceptKind
+	Names             []string
+	PrintSymbol       string
+	PrintSymbolMarkup *Markup
+	Provenance        string
 }
 
 func NewConcept(kind ConceptKind, code string, codeUC string) (*Concept, error) {
@@ -201,6 +325,14 @@ func (c Concept) GetCodeUC() string {
 func (c Concept) GetPrintSymbol() string {
 	return c.PrintSymbol
 }
+func (c Concept) GetPrintSymbolMarkup() *Markup {
+	return c.PrintSymbolMarkup
+}
+
+// true if the concept is not defined by the official essence file, but by an overlay
+func (c Concept) IsLocal() bool {
+	return c.Provenance != ""
+}
 
 func (c Concept) GetKind() ConceptKind {
 	return c.Kind
@@ -346,6 +478,16 @@ func (duf *DefinedUnitFilter)FilterByIsArbitrary(isArbitrary bool)*DefinedUnitFi
 	return NewDefinedUnitFilter(dul)
 }
 
+func (duf *DefinedUnitFilter)Filter(predicate DefinedUnitPredicate)*DefinedUnitFilter{
+	dul := make([]*DefinedUnit,0)
+	for _,du := range duf.DefinedUnits{
+		if predicate(du) {
+			dul = append(dul, du)
+		}
+	}
+	return NewDefinedUnitFilter(dul)
+}
+
 func (duf *DefinedUnitFilter)FilterByIsMetric(isMetric bool)*DefinedUnitFilter{
 	dul := make([]*DefinedUnit,0)
 	for _,du := range duf.DefinedUnits{
@@ -381,6 +523,60 @@ func (d DefinedUnit) GetDescription() string {
 	return strings.ToLower(d.Kind.String()) + " " + d.Code + " ('" + name + "')" + " (" + d.Property + ")" + " = " + d.Value.GetDescription()
 }
 
+//SynthesizedUnit=====================================================
+/**
+Parent is Unit
+A unit which is not a concept of the model, but is made from concepts of the model by a search:
+a metric unit with a prefix (e.g. mg, kPa, uL) or a composite unit (e.g. mg/dL).
+Prefix and Atom are set for a prefixed unit, Parts for a composite unit.
+ */
+type SynthesizedUnit struct {
+	Unit
+	Prefix *Prefix
+	Atom   Uniter
+	Parts  []Uniter
+}
+
+func NewPrefixedUnit(prefix *Prefix, atom Uniter) *SynthesizedUnit {
+	s := &SynthesizedUnit{}
+	s.Kind = UNIT
+	s.Code = prefix.Code + atom.GetCode()
+	s.CodeUC = prefix.CodeUC + atom.GetCodeUC()
+	s.Names = make([]string, 0)
+	if len(prefix.Names) > 0 && len(atom.GetNames()) > 0 {
+		s.Names = append(s.Names, prefix.Names[0]+atom.GetNames()[0])
+	}
+	s.PrintSymbol = prefix.PrintSymbol + atom.GetPrintSymbol()
+	s.Property = atom.GetProperty()
+	s.Prefix = prefix
+	s.Atom = atom
+	return s
+}
+
+// parts are divided by each other, e.g. mg, dL -> mg/dL
+func NewCompositeUnit(parts []Uniter) *SynthesizedUnit {
+	s := &SynthesizedUnit{}
+	s.Kind = UNIT
+	codes := make([]string, 0)
+	codesUC := make([]string, 0)
+	names := make([]string, 0)
+	printSymbols := make([]string, 0)
+	for _, part := range parts {
+		codes = append(codes, part.GetCode())
+		codesUC = append(codesUC, part.GetCodeUC())
+		if len(part.GetNames()) > 0 {
+			names = append(names, part.GetNames()[0])
+		}
+		printSymbols = append(printSymbols, part.GetPrintSymbol())
+	}
+	s.Code = strings.Join(codes, "/")
+	s.CodeUC = strings.Join(codesUC, "/")
+	s.Names = []string{strings.Join(names, " per ")}
+	s.PrintSymbol = strings.Join(printSymbols, "/")
+	s.Parts = parts
+	return s
+}
+
 //Prefix=====================================================
 /**
 Parent is Concept
@@ -408,11 +604,16 @@ func (p Prefix) GetDescription() string {
 }
 
 //Value=====================================================
+/**
+Text = the text content of the value in the essence file, e.g. "8.854187817 × 10⁻¹²", rendered as PrintSymbol
+TextMarkup = the text content with the markup of the essence file, nil if there is none
+ */
 type Value struct {
-	Text   string
-	Unit   string
-	UnitUC string
-	Value  decimal.Decimal
+	Text       string
+	TextMarkup *Markup
+	Unit       string
+	UnitUC     string
+	Value      decimal.Decimal
 }
 
 func NewValue(unit, unitUC string, value decimal.Decimal) (*Value, error) {
@@ -440,10 +641,26 @@ The canonical form itself consists of 3 columns: (4.1) the magnitude value of th
 (4.2) a canonical unit term; (4.3) if applicable a special conversion function code.
 
 A canonical unit is a unit of measurement agreed upon as default in a certain context.
+
+ArbitraryUnits holds the arbitrary units (e.g. [iU], [arb'U]) the canonical was derived from, with their exponents.
+Arbitrary units are not part of the canonical unit term, since they are defined as dimensionless,
+but quantities of different arbitrary units must not be compared.
+
+Dimensionless is set when no base units are left (%, [ppm], mol/mol, but not rad or sr, which is a base unit).
+RatioKinds holds the kinds of quantity which cancelled out in the expression, sorted. A kind is keyed on the
+dimension of the cancelled units, so mg/kg has kind g, L/L and m3/m3 have kind m3, and m/m has kind m.
+This may be a unit counting entities (amount of substance or information, e.g. mol for mol/mol and eq/eq),
+which is a pure number in UCUM. If no unit of the expression cancels out (e.g. m2/m.m), the base units
+which cancelled out are the kinds.
  */
 type Canonical struct {
-	Units []*CanonicalUnit
-	Value decimal.Decimal
+	Units          []*CanonicalUnit
+	Value          decimal.Decimal
+	ArbitraryUnits map[string]int
+	Dimensionless  bool
+	RatioKinds     []string
+	entities       map[string]int
+	kinds          map[string]int //the symbols of the expression counted per kind, see RatioKinds
 }
 
 func (c *Canonical) RemoveFromUnits(i int) {
@@ -464,8 +681,12 @@ func (c *Canonical) SortUnits() {
 
 func NewCanonical(value decimal.Decimal) (*Canonical, error) {
 	v := &Canonical{
-		Value: value,
-		Units: make([]*CanonicalUnit, 0),
+		Value:          value,
+		Units:          make([]*CanonicalUnit, 0),
+		ArbitraryUnits: make(map[string]int),
+		RatioKinds:     make([]string, 0),
+		entities:       make(map[string]int),
+		kinds:          make(map[string]int),
 	}
 	return v, nil
 }
@@ -486,6 +707,162 @@ func (c *Canonical) DivideValueInt(divisor int) {
 	c.Value = c.Value.Div(decimal.New(int64(divisor), 0))
 }
 
+func (c *Canonical) GetExponent(code string) int {
+	for _, cu := range c.Units {
+		if cu.Base.Code == code {
+			return cu.Exponent
+		}
+	}
+	return 0
+}
+
+// returns a deep copy of the canonical
+func (c *Canonical) Copy() *Canonical {
+	result, _ := NewCanonical(c.Value)
+	for _, cu := range c.Units {
+		unit, _ := NewCanonicalUnit(cu.Base, cu.Exponent)
+		result.Units = append(result.Units, unit)
+	}
+	result.AddArbitraryUnits(c.ArbitraryUnits, 1)
+	result.addEntities(c.entities, 1)
+	result.addKinds(c.kinds, 1)
+	result.Dimensionless = c.Dimensionless
+	result.RatioKinds = append(result.RatioKinds, c.RatioKinds...)
+	return result
+}
+
+// returns a copy of the canonical without the given base unit
+func (c *Canonical) WithoutUnit(code string) *Canonical {
+	result, _ := NewCanonical(c.Value)
+	for _, cu := range c.Units {
+		if cu.Base.Code != code {
+			result.Units = append(result.Units, cu)
+		}
+	}
+	result.AddArbitraryUnits(c.ArbitraryUnits, 1)
+	result.Dimensionless = len(result.Units) == 0
+	result.RatioKinds = append(result.RatioKinds, c.RatioKinds...)
+	return result
+}
+
+// adds the arbitrary units, with their exponents multiplied by factor
+func (c *Canonical) AddArbitraryUnits(arbitraryUnits map[string]int, factor int) {
+	for code, exponent := range arbitraryUnits {
+		c.ArbitraryUnits[code] = c.ArbitraryUnits[code] + exponent*factor
+		if c.ArbitraryUnits[code] == 0 {
+			delete(c.ArbitraryUnits, code)
+		}
+	}
+}
+
+func (c *Canonical) IsArbitrary() bool {
+	return len(c.ArbitraryUnits) > 0
+}
+
+// returns true if both canonicals are derived from the same arbitrary units
+func (c *Canonical) HasSameArbitraryUnits(other *Canonical) bool {
+	if len(c.ArbitraryUnits) != len(other.ArbitraryUnits) {
+		return false
+	}
+	for code, exponent := range c.ArbitraryUnits {
+		if other.ArbitraryUnits[code] != exponent {
+			return false
+		}
+	}
+	return true
+}
+
+// adds the entities, with their exponents multiplied by factor. Entities with exponent 0 are kept,
+// normaliseTerm records them as ratio kind
+func (c *Canonical) addEntities(entities map[string]int, factor int) {
+	for code, exponent := range entities {
+		c.entities[code] = c.entities[code] + exponent*factor
+	}
+}
+
+func (c *Canonical) addRatioKind(code string) {
+	for _, s := range c.RatioKinds {
+		if s == code {
+			return
+		}
+	}
+	c.RatioKinds = append(c.RatioKinds, code)
+	sort.Strings(c.RatioKinds)
+}
+
+// adds the kinds, with their counts multiplied by factor
+func (c *Canonical) addKinds(kinds map[string]int, factor int) {
+	for kind, count := range kinds {
+		c.kinds[kind] = c.kinds[kind] + count*factor
+	}
+}
+
+/**
+returns the kind of quantity of the canonical, as its dimension: the base units and entities with their
+exponents, e.g. m3 for L. The sign is the sign of the exponents, the kind of m-1 is m with sign -1.
+Pure numbers have no kind.
+ */
+func (c *Canonical) kind() (string, int) {
+	parts := make([]string, 0)
+	sign := 0
+	exponent := func(code string, e int) {
+		if sign == 0 {
+			sign = 1
+			if e < 0 {
+				sign = -1
+			}
+		}
+		e = e * sign
+		if e == 1 {
+			parts = append(parts, code)
+		} else {
+			parts = append(parts, code+strconv.Itoa(e))
+		}
+	}
+	units := append(make([]*CanonicalUnit, 0), c.Units...)
+	sort.Sort(ByCode(units))
+	for _, cu := range units {
+		exponent(cu.Base.Code, cu.Exponent)
+	}
+	entities := make([]string, 0)
+	for code, e := range c.entities {
+		if e != 0 {
+			entities = append(entities, code)
+		}
+	}
+	sort.Strings(entities)
+	for _, code := range entities {
+		exponent(code, c.entities[code])
+	}
+	return strings.Join(parts, "."), sign
+}
+
+// returns true if both canonicals are ratios of the same kind, or at least one of them is a pure number
+func (c *Canonical) HasSameRatioKind(other *Canonical) bool {
+	if len(c.RatioKinds) == 0 || len(other.RatioKinds) == 0 {
+		return true
+	}
+	if len(c.RatioKinds) != len(other.RatioKinds) {
+		return false
+	}
+	for i, kind := range c.RatioKinds {
+		if other.RatioKinds[i] != kind {
+			return false
+		}
+	}
+	return true
+}
+
+// returns the codes of the arbitrary units, sorted
+func (c *Canonical) GetArbitraryUnitCodes() []string {
+	result := make([]string, 0)
+	for code := range c.ArbitraryUnits {
+		result = append(result, code)
+	}
+	sort.Strings(result)
+	return result
+}
+
 //CanonicalUnit=====================================================
 /**
 base a canonical unit term;
@@ -514,10 +891,12 @@ type Component struct {
 /**
 Parent is component
 Connected with TokenType NUMBER
+Annotation is the text of an annotation (without braces), which the parser turns into a factor 1
  */
 type Factor struct {
 	Component
-	Value int
+	Value      int
+	Annotation string
 }
 
 func NewFactor(value int) *Factor {
@@ -527,6 +906,13 @@ func NewFactor(value int) *Factor {
 	return v
 }
 
+// a factor 1 for an annotation, e.g. {rbc}
+func NewAnnotationFactor(annotation string) *Factor {
+	v := NewFactor(1)
+	v.Annotation = annotation
+	return v
+}
+
 //Symbol=====================================================
 /**
 // Unit may be Base Unit or DefinedUnit
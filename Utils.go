@@ -1,6 +1,9 @@
 package ucum
 
-import "strconv"
+import (
+	"strconv"
+	"strings"
+)
 
 func IsDecimal(value string) bool {
 	if value == "" {
@@ -51,3 +54,33 @@ func MinInt(a, b int) int {
 func IsAsciiChar(ch rune) bool {
 	return ch >= ' ' && ch <= '~'
 }
+
+/**
+CompareVersions compares dot separated version numbers, e.g. "2.1" < "2.1.1" < "2.10".
+returns -1, 0 or 1. Parts which are not a number are compared as text.
+ */
+func CompareVersions(a, b string) int {
+	pa := strings.Split(strings.TrimSpace(a), ".")
+	pb := strings.Split(strings.TrimSpace(b), ".")
+	for i := 0; i < MaxInt(len(pa), len(pb)); i++ {
+		if i >= len(pa) {
+			return -1
+		}
+		if i >= len(pb) {
+			return 1
+		}
+		na, errA := strconv.Atoi(pa[i])
+		nb, errB := strconv.Atoi(pb[i])
+		if errA != nil || errB != nil {
+			if c := strings.Compare(pa[i], pb[i]); c != 0 {
+				return c
+			}
+		} else if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
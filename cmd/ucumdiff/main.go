@@ -0,0 +1,37 @@
+/**
+ucumdiff reports the differences between two versions of the UCUM essence file:
+added, removed and renamed concepts, changed definitions, and units of which the canonical form changed.
+
+	ucumdiff old/ucum-essence.xml new/ucum-essence.xml
+
+The exit code is 0 if there are no differences, 1 if there are, 2 on an error.
+ */
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bertverhees/ucum"
+)
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintln(os.Stderr, "usage: ucumdiff old-essence.xml new-essence.xml")
+		os.Exit(2)
+	}
+	oldService, err := ucum.NewUcumEssenceService(os.Args[1], new(ucum.DefinitionParser))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, os.Args[1]+": "+err.Error())
+		os.Exit(2)
+	}
+	diff, err := oldService.DiffVersion(os.Args[2])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, os.Args[2]+": "+err.Error())
+		os.Exit(2)
+	}
+	fmt.Print(diff.String())
+	if !diff.IsEmpty() {
+		os.Exit(1)
+	}
+}
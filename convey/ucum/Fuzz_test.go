@@ -0,0 +1,105 @@
+package ucum
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bertverhees/ucum"
+	"github.com/bertverhees/ucum/decimal"
+)
+
+/**
+Fuzz targets for the parsers and the converter, which must return an error for any input, never panic.
+Run with e.g. go test ./convey/ucum -run XXX -fuzz FuzzExpressionParser -fuzztime 1m
+Without -fuzz, the seeds are run as tests.
+ */
+
+var fuzzUnits = []string{"", "m", "mg/dL", "mg{creat}", "{rbc}/uL", "/min", "mg/(kg.d)", "4.[pi].10*-7.N/A2",
+	"10*9/L", "m+2", "Cel", "[degF]", "[pH]", "[iU]/L", "(", ")", "[", "{", "m/", "μg", "m²", "10*2147483648", "+", "-",
+	"m-99999", "10*-99999", "((((m))))", "[H2O]", "m[H2O]", "/0", "/B", "mB/B", "m/(0)", "//0",
+	"1000000.1000000.1000000.1000000",
+	"µg", "°C", "×10⁹/L", "10⁻³", "m·s⁻²", "{µ}", "\xff"}
+
+func FuzzExpressionParser(f *testing.F) {
+	InitService()
+	for _, unit := range fuzzUnits {
+		f.Add(unit)
+	}
+	parser := ucum.NewExpressionParser(service.Model)
+	f.Fuzz(func(t *testing.T, unit string) {
+		term, err := parser.Parse(unit)
+		if err != nil {
+			return
+		}
+		composed, err := ucum.ComposeExpressionErr(term, false)
+		if err != nil {
+			t.Fatalf("%q does not compose: %v", unit, err)
+		}
+		reparsed, err := parser.Parse(composed)
+		if err != nil {
+			t.Fatalf("%q composed as %q does not parse: %v", unit, composed, err)
+		}
+		if !ucum.EqualTerms(term, reparsed) {
+			t.Fatalf("%q composed as %q parses to another structure", unit, composed)
+		}
+		ucum.ComposeFormalStructure(term)
+		ucum.NormalizeTerm(term)
+		ucum.NewPrintSymbolRenderer(ucum.PRINT_UNICODE).Render(term)
+		service.Validate(unit)
+		service.Analyse(unit)
+		service.GetCanonicalUnits(unit)
+	})
+}
+
+func FuzzLookalikes(f *testing.F) {
+	InitService()
+	for _, unit := range fuzzUnits {
+		f.Add(unit)
+	}
+	parser := ucum.NewLenientExpressionParser(service.Model)
+	f.Fuzz(func(t *testing.T, unit string) {
+		replaced, _, err := ucum.ReplaceLookalikes(unit)
+		if err != nil {
+			return
+		}
+		for i := 0; i < len(replaced); i++ {
+			if replaced[i] >= 0x80 {
+				t.Fatalf("%q replaced as %q is not ASCII", unit, replaced)
+			}
+		}
+		parser.Parse(unit)
+	})
+}
+
+func FuzzConvert(f *testing.F) {
+	InitService()
+	for _, source := range fuzzUnits {
+		f.Add("1", source, "m")
+		f.Add("-0.5", source, source)
+	}
+	f.Add("37", "Cel", "[degF]")
+	f.Add("1e10", "kg", "[lb_av]")
+	f.Fuzz(func(t *testing.T, value, source, destination string) {
+		d, err := decimal.NewFromString(value)
+		if err != nil {
+			return
+		}
+		service.Convert(d, source, destination)
+		service.IsComparable(source, destination)
+	})
+}
+
+func FuzzDefinitionParser(f *testing.F) {
+	f.Add([]byte(`<root version="2.1" revision="$Revision: 442 $" revision-date="$Date: 2017-11-21 19:04:52 -0500 (Tue, 21 Nov 2017) $">` +
+		`<prefix Code="k" CODE="K"><name>kilo</name><printSymbol>k</printSymbol><value value="1e3">10<sup>3</sup></value></prefix>` +
+		`<base-unit Code="m" CODE="M" dim="L"><name>meter</name><printSymbol>m</printSymbol><property>length</property></base-unit>` +
+		`<unit Code="[in_i]" CODE="[IN_I]" isMetric="no" class="intcust"><name>inch</name><printSymbol>in</printSymbol>` +
+		`<property>length</property><value Unit="cm" UNIT="CM" value="2.54">2.54</value></unit></root>`))
+	f.Add([]byte(`<root><unit Code="x"><value Unit="" value=""/></unit></root>`))
+	f.Add([]byte(`<root revision-date="garbage"/>`))
+	f.Add([]byte(``))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		new(ucum.DefinitionParser).UnmarshalTerminology(bytes.NewReader(data))
+		ucum.NewStrictDefinitionParser(true).UnmarshalTerminology(bytes.NewReader(data))
+	})
+}
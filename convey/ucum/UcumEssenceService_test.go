@@ -8,7 +8,12 @@ import (
 	"fmt"
 	"reflect"
 	"github.com/bertverhees/ucum/decimal"
+	"sort"
 	"strings"
+	"time"
+	"io/ioutil"
+	"math/rand"
+	"strconv"
 )
 
 var test string
@@ -93,7 +98,23 @@ func TestGetPropertiesTests(t *testing.T) {
 	InitService()
 	Convey("TestGetPropertiesTests", t, func() {
 		list := service.GetProperties()
-		So(len(list), ShouldBeGreaterThan, 300)
+		So(len(list), ShouldEqual, 100)
+		So(sort.StringsAreSorted(list), ShouldBeTrue)
+		units := service.GetUnitsForProperty("length")
+		So(len(units), ShouldEqual, 44)
+		So(units[0].GetCode(), ShouldEqual, "m")
+		info, err := service.GetPropertyInfo("length")
+		So(err, ShouldBeNil)
+		So(info.UnitCount, ShouldEqual, 44)
+		So(info.Canonical, ShouldEqual, "m")
+		So(info.BaseUnit.Code, ShouldEqual, "m")
+		So(info.ExampleUnits[0], ShouldEqual, "m")
+		info, err = service.GetPropertyInfo("mass concentration")
+		So(err, ShouldBeNil)
+		So(info.Canonical, ShouldEqual, "g.m-3")
+		So(info.BaseUnit, ShouldBeNil)
+		_, err = service.GetPropertyInfo("no such property")
+		So(err, ShouldNotBeNil)
 	})
 }
 
@@ -125,6 +146,46 @@ func TestSearchUnitsTests(t *testing.T) {
 }
 
 
+func TestSearchConceptsTests(t *testing.T) {
+	InitService()
+	Convey("TestSearchConceptsTests", t, func() {
+		prefixes, err := service.SearchConcepts("", false, ucum.NewSearchOptions(ucum.PREFIX))
+		So(err, ShouldBeNil)
+		So(len(prefixes), ShouldEqual, len(service.Model.Prefixes))
+		list, err := service.SearchConcepts("", false, nil)
+		So(err, ShouldBeNil)
+		So(len(list), ShouldEqual, len(service.Model.Prefixes)+len(service.Model.BaseUnits)+len(service.Model.DefinedUnits))
+		list, err = service.SearchConcepts("meter", false, ucum.NewSearchOptions(ucum.BASEUNIT, ucum.UNIT))
+		So(err, ShouldBeNil)
+		So(list[0].GetCode(), ShouldEqual, "m")
+		for _, c := range list {
+			So(c.GetKind(), ShouldNotEqual, ucum.PREFIX)
+		}
+		options := ucum.NewSearchOptions()
+		options.Property = "length"
+		list, err = service.SearchConcepts("", false, options)
+		So(err, ShouldBeNil)
+		So(list[0].GetCode(), ShouldEqual, "m")
+		So(len(list), ShouldEqual, len(service.GetUnitsForProperty("length")))
+		options = ucum.NewSearchOptions()
+		options.Class = "clinical"
+		options.OnIsArbitrary, options.IsArbitrary = true, true
+		list, err = service.SearchConcepts("", false, options)
+		So(err, ShouldBeNil)
+		So(len(list), ShouldBeGreaterThan, 0)
+		for _, c := range list {
+			So(c.(*ucum.DefinedUnit).Class, ShouldEqual, "clinical")
+			So(c.(*ucum.DefinedUnit).IsArbitrary, ShouldBeTrue)
+		}
+		options.Limit = 2
+		list, err = service.SearchConcepts("", false, options)
+		So(err, ShouldBeNil)
+		So(len(list), ShouldEqual, 2)
+		_, err = service.SearchConcepts("m([a-z]+r", true, nil)
+		So(err, ShouldNotBeNil)
+	})
+}
+
 func TestSearchBaseUnitsTests(t *testing.T) {
 	InitService()
 	Convey("TestSearchBaseUnitsTests", t, func() {
@@ -147,6 +208,8 @@ func TestSearchBaseUnitsTests(t *testing.T) {
 		So(err, ShouldBeNil)
 		p6 := list[0]
 		So(reflect.DeepEqual(p1,p6), ShouldBeTrue)
+		So(service.Model.BaseUnitsByCode["m"].Dim, ShouldEqual, 'L')
+		So(service.Model.BaseUnitsByCode["cd"].Dim, ShouldEqual, 'F')
 		list, err = service.Search(ucum.BASEUNIT, "^m([a-z]+)r", true)
 		So(err, ShouldBeNil)
 		p5 := list[0]
@@ -179,6 +242,568 @@ func TestSearchBaseUnitsTests(t *testing.T) {
 	})
 }
 
+func TestConceptMetadataTests(t *testing.T) {
+	InitService()
+	Convey("TestConceptMetadataTests", t, func() {
+		unit := service.Model.GetUnit("gon").(*ucum.DefinedUnit)
+		So(unit.Names, ShouldResemble, []string{"gon", "grade"})
+		list, err := service.Search(ucum.UNIT, "British ton", false)
+		So(err, ShouldBeNil)
+		So(list[0].GetCode(), ShouldEqual, "[lton_av]")
+		unit = service.Model.GetUnit("a_t").(*ucum.DefinedUnit)
+		So(unit.PrintSymbol, ShouldEqual, "aₜ")
+		So(unit.PrintSymbolMarkup.Plain(), ShouldEqual, "a_t")
+		So(unit.PrintSymbolMarkup.HTML(), ShouldEqual, "a<sub>t</sub>")
+		unit = service.Model.GetUnit("[eps_0]").(*ucum.DefinedUnit)
+		So(unit.PrintSymbol, ShouldEqual, "ε₀")
+		So(unit.PrintSymbolMarkup.HTML(), ShouldEqual, "<i>ε<sub>0</sub></i>")
+		So(unit.Value.Text, ShouldEqual, "8.854187817 × 10⁻¹²")
+		So(unit.Value.TextMarkup.Plain(), ShouldEqual, "8.854187817 × 10^-12")
+		So(unit.Value.TextMarkup.HTML(), ShouldEqual, "8.854187817 × 10<sup>-12</sup>")
+		list, err = service.Search(ucum.PREFIX, "micro", false)
+		So(err, ShouldBeNil)
+		So(list[0].GetPrintSymbol(), ShouldEqual, "μ")
+		So(list[0].GetNames(), ShouldResemble, []string{"micro"})
+		unit = service.Model.GetUnit("[pi]").(*ucum.DefinedUnit)
+		So(unit.PrintSymbol, ShouldEqual, "π")
+		So(unit.PrintSymbolMarkup.LaTeX(), ShouldEqual, `\pi{}`)
+	})
+}
+
+func TestTranslationsTests(t *testing.T) {
+	InitService()
+	Convey("TestTranslationsTests", t, func() {
+		Convey("Analyse", func() {
+			analysed, err := service.AnalyseIn("mg/dL", "de")
+			So(err, ShouldBeNil)
+			So(analysed, ShouldEqual, "(Milligramm) / (Deziliter)")
+			analysed, _ = service.AnalyseIn("", "fr")
+			So(analysed, ShouldEqual, "(unité)")
+			analysed, _ = service.AnalyseIn("10*3/uL", "nl")
+			So(analysed, ShouldEqual, "(het getal tien voor willekeurige machten ^ 3) / (microliter)")
+			_, err = service.AnalyseIn("m", "xx")
+			So(err, ShouldNotBeNil)
+			So(service.GetCommonDisplayIn("kg", "fr"), ShouldEqual, "(kilogramme)")
+		})
+		Convey("FormatQuantity", func() {
+			format := func(value, unit, language string) string {
+				s, err := service.FormatQuantity(decimal.RequireFromString(value), unit, language)
+				So(err, ShouldBeNil)
+				return s
+			}
+			So(format("1", "mg", "en"), ShouldEqual, "1 milligram")
+			So(format("2", "mg", "en"), ShouldEqual, "2 milligrams")
+			So(format("2.5", "mg", "nl"), ShouldEqual, "2,5 milligram")
+			So(format("3", "d", "nl"), ShouldEqual, "3 dagen")
+			So(format("1234.5", "g", "de"), ShouldEqual, "1.234,5 Gramm")
+			So(format("1.5", "g", "fr"), ShouldEqual, "1,5 gramme")
+			So(format("2", "g", "fr"), ShouldEqual, "2 grammes")
+			So(format("1000000", "1", "en"), ShouldEqual, "1,000,000")
+			So(format("2", "mg/dL", "en"), ShouldEqual, "2 (milligrams) / (deciliter)")
+			So(format("2", "/uL", "en"), ShouldEqual, "2 / (microliter)")
+			So(format("1", "/uL", "en"), ShouldEqual, "1 / (microliter)")
+			So(format("2", "{cells}/uL", "en"), ShouldEqual, "2 / (microliter)")
+			So(format("1", "{beats}/min", "en"), ShouldEqual, "1 / (minute)")
+			So(format("2", "mg{total}/dL", "en"), ShouldEqual, "2 (milligrams) / (deciliter)")
+			So(format("2", "/dL.mg", "en"), ShouldEqual, "2 / (deciliter) * (milligrams)")
+			So(format("2", "{cells}", "en"), ShouldEqual, "2")
+		})
+		Convey("Search", func() {
+			list, err := service.SearchTranslated("nl", "dag")
+			So(err, ShouldBeNil)
+			So(list[0].GetCode(), ShouldEqual, "d")
+			list, _ = service.SearchTranslated("de", "zenti")
+			So(list[0].GetKind(), ShouldEqual, ucum.PREFIX)
+			So(list[0].GetCode(), ShouldEqual, "c")
+		})
+		Convey("Load", func() {
+			definitions := os.Getenv("GOPATH") + "/src/github.com/bertverhees/ucum/terminology_data/ucum-essence.xml"
+			resources := os.Getenv("GOPATH") + "/src/github.com/bertverhees/ucum/convey/resources/"
+			localService, err := ucum.NewUcumEssenceService(definitions, new(ucum.DefinitionParser))
+			So(err, ShouldBeNil)
+			So(localService.LoadTranslations(resources+"translations_es.json"), ShouldBeNil)
+			analysed, _ := localService.AnalyseIn("mg/min", "es")
+			So(analysed, ShouldEqual, "(miligramo) / (minuto)")
+			s, _ := localService.FormatQuantity(decimal.RequireFromString("2"), "kg", "ES")
+			So(s, ShouldEqual, "2 kilogramos")
+			done := make(chan bool)
+			for i := 0; i < 4; i++ {
+				go func() {
+					localService.LoadTranslations(resources + "translations_es.json")
+					localService.AnalyseIn("mg/min", "es")
+					done <- true
+				}()
+			}
+			for i := 0; i < 4; i++ {
+				<-done
+			}
+		})
+	})
+}
+
+func TestRenderNaturalTests(t *testing.T) {
+	InitService()
+	Convey("TestRenderNaturalTests", t, func() {
+		render := func(unit string, plural bool) string {
+			s, err := service.RenderNatural(unit, plural)
+			So(err, ShouldBeNil)
+			return s
+		}
+		quantity := func(value, unit string) string {
+			s, err := service.RenderNaturalQuantity(decimal.RequireFromString(value), unit)
+			So(err, ShouldBeNil)
+			return s
+		}
+		So(render("mg/dL", true), ShouldEqual, "milligrams per deciliter")
+		So(render("m/s2", true), ShouldEqual, "meters per second squared")
+		So(render("m.s-2", true), ShouldEqual, "meters per second squared")
+		So(render("cm3", false), ShouldEqual, "cubic centimeter")
+		So(render("m2", true), ShouldEqual, "square meters")
+		So(render("/uL", false), ShouldEqual, "per microliter")
+		So(render("N.m", true), ShouldEqual, "newton meters")
+		So(render("mL/(12.h)", true), ShouldEqual, "milliliters per 12 hours")
+		So(render("10*9/L", false), ShouldEqual, "ten to the power 9 per liter")
+		So(render("m4", false), ShouldEqual, "meter to the power 4")
+		So(render("", false), ShouldEqual, "unity")
+		So(quantity("5", "mg/kg/d"), ShouldEqual, "5 milligrams per kilogram per day")
+		So(quantity("5", "mg/(kg.d)"), ShouldEqual, "5 milligrams per kilogram per day")
+		So(quantity("1", "mg/kg"), ShouldEqual, "1 milligram per kilogram")
+		So(quantity("72", "{beats}/min"), ShouldEqual, "72 beats per minute")
+		So(render("{cells}/uL", false), ShouldEqual, "cells per microliter")
+		So(render("mg{creat}/dL", true), ShouldEqual, "milligrams per deciliter")
+		So(render("mg/{dose}", true), ShouldEqual, "milligrams per dose")
+		So(render("kg/m2", true), ShouldEqual, "kilograms per square meter")
+		So(render("mL/m3", true), ShouldEqual, "milliliters per cubic meter")
+		So(quantity("3", "1"), ShouldEqual, "3")
+		_, err := service.RenderNatural("mg/", false)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestParseNaturalTests(t *testing.T) {
+	InitService()
+	Convey("TestParseNaturalTests", t, func() {
+		parse := func(phrase string) string {
+			s, err := service.ParseNatural(phrase)
+			So(err, ShouldBeNil)
+			return s
+		}
+		So(parse("milligrams per deciliter"), ShouldEqual, "mg/dL")
+		So(parse("micrograms per kilogram per minute"), ShouldEqual, "ug/kg/min")
+		So(parse("beats per minute"), ShouldEqual, "{beats}/min")
+		So(parse("square meters"), ShouldEqual, "m2")
+		So(parse("Degrees Celsius"), ShouldEqual, "Cel")
+		So(parse("meters per second squared"), ShouldEqual, "m/s2")
+		So(parse("cubic centimeter"), ShouldEqual, "cm3")
+		So(parse("per microliter"), ShouldEqual, "/uL")
+		So(parse("millimeters of mercury column"), ShouldEqual, "mm[Hg]")
+		So(parse("international units per liter"), ShouldEqual, "[iU]/L")
+		So(parse("feet"), ShouldEqual, "[ft_i]")
+		So(parse("milliliters per 12 hours"), ShouldEqual, "mL/(12.h)")
+		So(parse("ten to the power 9 per liter"), ShouldEqual, "10*9/L")
+		So(parse("meter to the power of 4"), ShouldEqual, "m4")
+		So(parse("cells per microliter"), ShouldEqual, "{cells}/uL")
+		So(parse("milligrams per dose"), ShouldEqual, "mg/{dose}")
+		for _, phrase := range []string{"", "flurbs per glorp", "milligrams per", "square", "beats milligrams",
+			"miligrams per deciliter", "mcg per kilogram", "ten", "flurbs per minute"} {
+			_, err := service.ParseNatural(phrase)
+			So(err, ShouldNotBeNil)
+		}
+		_, err := service.ParseNatural("miligrams per deciliter")
+		So(err.Error(), ShouldContainSubstring, "unknown unit 'miligrams'")
+		Convey("Round trip", func() {
+			for _, unit := range []string{"mg/dL", "m/s2", "cm3", "/uL", "N.m", "mg/kg/d", "10*9/L", "mL/(12.h)", "kg/m2", "{beats}/min"} {
+				rendered, err := service.RenderNatural(unit, true)
+				So(err, ShouldBeNil)
+				parsed := parse(rendered)
+				comparable, _ := service.IsComparable(parsed, unit)
+				So(comparable, ShouldBeTrue)
+			}
+		})
+	})
+}
+
+type operatorCounter struct {
+	ucum.BaseExpressionVisitor
+	operators int
+	nested    int
+	inverted  []string
+}
+
+func (v *operatorCounter) VisitOperator(op ucum.Operator) {
+	v.operators++
+}
+
+func (v *operatorCounter) EnterTerm(term *ucum.Term, inverted bool) bool {
+	v.nested++
+	return true
+}
+
+func (v *operatorCounter) VisitSymbol(symbol *ucum.Symbol, inverted bool) {
+	if inverted {
+		v.inverted = append(v.inverted, symbol.Unit.GetCode())
+	}
+}
+
+func TestExpressionVisitorTests(t *testing.T) {
+	InitService()
+	Convey("TestExpressionVisitorTests", t, func() {
+		parse := func(unit string) *ucum.Term {
+			term, err := ucum.NewExpressionParser(service.Model).Parse(unit)
+			So(err, ShouldBeNil)
+			return term
+		}
+		Convey("Walk", func() {
+			counter := &operatorCounter{}
+			ucum.WalkExpression(parse("mg/(kg.d).m"), counter)
+			So(counter.operators, ShouldEqual, 3)
+			So(counter.nested, ShouldEqual, 1)
+			So(counter.inverted, ShouldResemble, []string{"g", "d"})
+		})
+		Convey("Collect", func() {
+			term := parse("mg/kg.g/ug")
+			codes := make([]string, 0)
+			for _, atom := range ucum.CollectAtoms(term) {
+				codes = append(codes, atom.GetCode())
+			}
+			So(codes, ShouldResemble, []string{"g"})
+			codes = make([]string, 0)
+			for _, prefix := range ucum.CollectPrefixes(term) {
+				codes = append(codes, prefix.Code)
+			}
+			So(codes, ShouldResemble, []string{"m", "k", "u"})
+		})
+		Convey("Replace", func() {
+			term := parse("mg/dL")
+			replaced := ucum.ReplaceAtom(term, "g", service.Model.GetUnit("mol"))
+			So(ucum.ComposeExpression(replaced, false), ShouldEqual, "mmol/dL")
+			So(ucum.ComposeExpression(term, false), ShouldEqual, "mg/dL")
+		})
+		Convey("ComposeErr", func() {
+			composed, err := ucum.ComposeExpressionErr(parse("mg{creat}/(kg.d)"), false)
+			So(err, ShouldBeNil)
+			So(composed, ShouldEqual, "mg{creat}/(kg.d)")
+			composed, err = ucum.ComposeExpressionErr(nil, false)
+			So(err, ShouldBeNil)
+			So(composed, ShouldEqual, "1")
+			_, err = ucum.ComposeExpressionErr("mg", false)
+			So(err, ShouldNotBeNil)
+			_, err = ucum.ComposeExpressionErr(&ucum.Term{Comp: &ucum.Symbol{}}, false)
+			So(err, ShouldNotBeNil)
+			_, err = ucum.ComposeExpressionErr(&ucum.Term{Op: ucum.DIVISION, Term: &ucum.Term{Comp: &ucum.Term{Comp: "m"}}}, false)
+			So(err, ShouldNotBeNil)
+			_, err = ucum.ComposeExpressionErr(&ucum.Canonical{Units: []*ucum.CanonicalUnit{{Exponent: 1}}}, false)
+			So(err, ShouldNotBeNil)
+		})
+		Convey("Invert", func() {
+			So(ucum.ComposeExpression(ucum.InvertTerm(parse("mg/dL")), false), ShouldEqual, "/mg.dL")
+			So(ucum.ComposeExpression(ucum.InvertTerm(parse("/min")), false), ShouldEqual, "min")
+			inverted := ucum.ComposeExpression(ucum.InvertTerm(parse("4.m/(s.2)")), false)
+			comparable, _ := service.IsComparable(inverted, "s/m")
+			So(comparable, ShouldBeTrue)
+		})
+		Convey("Flatten", func() {
+			flat, err := ucum.FlattenTerm(parse("4.mg/(kg.d)/mg.kg2/6"))
+			So(err, ShouldBeNil)
+			So(flat.Numerator, ShouldEqual, 2)
+			So(flat.Denominator, ShouldEqual, 3)
+			So(len(flat.Symbols), ShouldEqual, 2)
+			So(ucum.ComposeExpression(flat.ToTerm(), false), ShouldEqual, "2.kg.d-1/3")
+			flat, err = ucum.FlattenTerm(parse("m/m"))
+			So(err, ShouldBeNil)
+			So(ucum.ComposeExpression(flat.ToTerm(), false), ShouldEqual, "1")
+			_, err = ucum.FlattenTerm(parse("1000000.1000000.1000000.1000000"))
+			So(err, ShouldNotBeNil)
+			_, err = ucum.FlattenTerm(parse("m/(1000000.1000000.1000000.1000000)"))
+			So(err, ShouldNotBeNil)
+			_, err = service.NormalizeUnit("1000000.1000000.1000000.1000000")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestNormalizeUnitTests(t *testing.T) {
+	InitService()
+	Convey("TestNormalizeUnitTests", t, func() {
+		normalize := func(unit string) string {
+			s, err := service.NormalizeUnit(unit)
+			So(err, ShouldBeNil)
+			return s
+		}
+		for _, unit := range []string{"mg/dL", "mg.dL-1", "/dL.mg", "mg/dL{creat}", "(mg.L)/(dL.L)"} {
+			So(normalize(unit), ShouldEqual, "mg/dL")
+		}
+		So(normalize("mg/kg/d"), ShouldEqual, "mg/(d.kg)")
+		So(normalize("mg/(d.kg)"), ShouldEqual, "mg/(d.kg)")
+		So(normalize("s-2.m.kg"), ShouldEqual, "kg.m/s2")
+		So(normalize("m.m.m"), ShouldEqual, "m3")
+		So(normalize("/min"), ShouldEqual, "/min")
+		So(normalize("{beats}/min"), ShouldEqual, "/min")
+		So(normalize("mg{creat}"), ShouldEqual, normalize("mg"))
+		So(normalize("m/m"), ShouldEqual, "1")
+		So(normalize(""), ShouldEqual, "1")
+		So(normalize("g.mg"), ShouldEqual, "g.mg")
+		So(normalize("4.m/(s.6)"), ShouldEqual, "2.m/(3.s)")
+		So(normalize("10*9/L"), ShouldEqual, "10*9/L")
+		Convey("the normal form is a valid expression with the same normal form", func() {
+			for _, unit := range []string{"mg/kg/d", "s-2.m.kg", "4.m/(s.6)", "/min", "mol/mol", "[iU]/L"} {
+				n := normalize(unit)
+				So(normalize(n), ShouldEqual, n)
+				comparable, err := service.IsComparable(n, unit)
+				So(err, ShouldBeNil)
+				So(comparable, ShouldBeTrue)
+			}
+		})
+		_, err := service.NormalizeUnit("mg/")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+// generates a random expression of the units and prefixes of the model, with annotations and parentheses
+func generateExpression(r *rand.Rand, depth int) string {
+	var b strings.Builder
+	if r.Intn(8) == 0 {
+		b.WriteString("/")
+	}
+	count := 1 + r.Intn(4)
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			b.WriteString([]string{".", "/"}[r.Intn(2)])
+		}
+		switch n := r.Intn(10); {
+		case n < 6:
+			unit := service.Model.DefinedUnits[r.Intn(len(service.Model.DefinedUnits))]
+			if unit.Metric && r.Intn(3) == 0 {
+				b.WriteString(service.Model.Prefixes[r.Intn(len(service.Model.Prefixes))].Code)
+			}
+			b.WriteString(unit.Code)
+			if r.Intn(3) == 0 {
+				b.WriteString([]string{"2", "3", "-1", "-2", "+2"}[r.Intn(5)])
+			}
+			if r.Intn(6) == 0 {
+				b.WriteString("{a" + strconv.Itoa(r.Intn(10)) + "}")
+			}
+		case n < 7:
+			b.WriteString(strconv.Itoa(1 + r.Intn(1000)))
+		case n < 8:
+			b.WriteString("{note " + strconv.Itoa(r.Intn(10)) + "}")
+		case depth > 0:
+			b.WriteString("(" + generateExpression(r, depth-1) + ")")
+		default:
+			b.WriteString(service.Model.BaseUnits[r.Intn(len(service.Model.BaseUnits))].Code)
+		}
+	}
+	return b.String()
+}
+
+func TestRoundTripCompositionTests(t *testing.T) {
+	InitService()
+	Convey("TestRoundTripCompositionTests", t, func() {
+		parser := ucum.NewExpressionParser(service.Model)
+		roundTrip := func(unit string) string {
+			term, err := parser.Parse(unit)
+			So(err, ShouldBeNil)
+			composed := ucum.ComposeExpression(term, false)
+			reparsed, err := parser.Parse(composed)
+			So(err, ShouldBeNil)
+			So(ucum.EqualTerms(term, reparsed), ShouldBeTrue)
+			return composed
+		}
+		So(roundTrip("mg{creat}"), ShouldEqual, "mg{creat}")
+		So(roundTrip("{rbc}/uL"), ShouldEqual, "{rbc}/uL")
+		So(roundTrip("/min"), ShouldEqual, "/min")
+		So(roundTrip("mg/(kg.d)"), ShouldEqual, "mg/(kg.d)")
+		So(roundTrip("kg/m.s2"), ShouldEqual, "kg/m.s2")
+		So(roundTrip("m+2"), ShouldEqual, "m2")
+		So(roundTrip("10.{a}/(/s.{b})"), ShouldEqual, "10{a}/(/s{b})")
+		So(roundTrip("4.[pi].10*-7.N/A2"), ShouldEqual, "4.[pi].10*-7.N/A2")
+		a, _ := parser.Parse("mg{a}")
+		b, _ := parser.Parse("mg{b}")
+		So(ucum.EqualTerms(a, b), ShouldBeFalse)
+		Convey("generated expressions", func() {
+			r := rand.New(rand.NewSource(48))
+			valid := 0
+			for i := 0; i < 3000; i++ {
+				unit := generateExpression(r, 2)
+				term, err := parser.Parse(unit)
+				if err != nil {
+					continue
+				}
+				valid++
+				composed := ucum.ComposeExpression(term, false)
+				reparsed, err := parser.Parse(composed)
+				if err != nil || !ucum.EqualTerms(term, reparsed) {
+					So(unit+" -> "+composed, ShouldBeEmpty)
+				}
+			}
+			So(valid, ShouldBeGreaterThan, 1000)
+		})
+	})
+}
+
+func TestMalformedInputTests(t *testing.T) {
+	InitService()
+	Convey("TestMalformedInputTests", t, func() {
+		parser := ucum.NewExpressionParser(service.Model)
+		for _, unit := range []string{"m²", "μg", "m/", "(", "[", "{"} {
+			_, err := parser.Parse(unit)
+			So(err, ShouldNotBeNil)
+		}
+		one := decimal.New(1, 0)
+		_, err := service.Convert(one, "/0", "1")
+		So(err, ShouldNotBeNil)
+		_, err = service.Convert(one, "m/(0)", "m")
+		So(err, ShouldNotBeNil)
+		_, err = service.Convert(one, "mB/B", "1")
+		So(err, ShouldNotBeNil)
+		_, err = service.Convert(one, "m99999", "m")
+		So(err, ShouldNotBeNil)
+		_, err = service.Convert(one, "10*2147483648", "1")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestUnicodeLookalikeTests(t *testing.T) {
+	InitService()
+	Convey("TestUnicodeLookalikeTests", t, func() {
+		parser := ucum.NewExpressionParser(service.Model)
+		rejected := func(unit string) *ucum.Lookalike {
+			_, err := parser.Parse(unit)
+			So(err, ShouldNotBeNil)
+			unicodeError, instanceof := err.(*ucum.UnicodeError)
+			So(instanceof, ShouldBeTrue)
+			return unicodeError.Lookalike
+		}
+		Convey("strict", func() {
+			l := rejected("µg")
+			So(l.Code, ShouldEqual, ucum.MICRO_SIGN)
+			So(l.Replacement, ShouldEqual, "u")
+			So(l.Position, ShouldEqual, 0)
+			l = rejected("m²")
+			So(l.Code, ShouldEqual, ucum.SUPERSCRIPT)
+			So(l.Replacement, ShouldEqual, "2")
+			So(l.Position, ShouldEqual, 1)
+			l = rejected("°C")
+			So(l.Code, ShouldEqual, ucum.DEGREE_SIGN)
+			So(l.Text, ShouldEqual, "°C")
+			So(l.Replacement, ShouldEqual, "Cel")
+			l = rejected("mg/dL·h")
+			So(l.Code, ShouldEqual, ucum.MULTIPLICATION_SIGN)
+			So(l.Position, ShouldEqual, 5)
+			So(rejected("{µ}").Code, ShouldEqual, ucum.MICRO_SIGN)
+			So(rejected("[µ]").Code, ShouldEqual, ucum.MICRO_SIGN)
+			So(rejected("m€").Code, ShouldEqual, ucum.NON_ASCII)
+			_, err := parser.Parse("µg")
+			So(err.Error(), ShouldContainSubstring, "write 'u' instead")
+		})
+		Convey("lenient", func() {
+			lenient := func(unit, expected string, codes ...ucum.LookalikeCode) {
+				code, replacements, err := service.ReplaceLookalikes(unit)
+				So(err, ShouldBeNil)
+				So(code, ShouldEqual, expected)
+				So(len(replacements), ShouldEqual, len(codes))
+				for i, c := range codes {
+					So(replacements[i].Code, ShouldEqual, c)
+				}
+			}
+			lenient("µg", "ug", ucum.MICRO_SIGN)
+			lenient("μmol/L", "umol/L", ucum.MICRO_SIGN)
+			lenient("m²", "m2", ucum.SUPERSCRIPT)
+			lenient("m·s⁻²", "m.s-2", ucum.MULTIPLICATION_SIGN, ucum.SUPERSCRIPT)
+			lenient("°C", "Cel", ucum.DEGREE_SIGN)
+			lenient("°F", "[degF]", ucum.DEGREE_SIGN)
+			lenient("×10⁹/L", "10*9/L", ucum.MULTIPLICATION_SIGN, ucum.SUPERSCRIPT)
+			lenient("10⁻³", "10*-3", ucum.SUPERSCRIPT)
+			lenient("kΩ", "kOhm", ucum.OHM_SIGN)
+			lenient("mg/dL", "mg/dL")
+			_, _, err := service.ReplaceLookalikes("m€")
+			So(err, ShouldNotBeNil)
+			term, err := ucum.NewLenientExpressionParser(service.Model).Parse("µg/m³")
+			So(err, ShouldBeNil)
+			So(ucum.ComposeExpression(term, false), ShouldEqual, "ug/m3")
+			parser := ucum.NewExpressionParser(service.Model)
+			_, err = parser.Parse("µg")
+			So(err, ShouldNotBeNil)
+			term, replacements, err := parser.ParseLenient("µg/m³")
+			So(err, ShouldBeNil)
+			So(ucum.ComposeExpression(term, false), ShouldEqual, "ug/m3")
+			So(len(replacements), ShouldEqual, 2)
+			_, replacements, err = parser.ParseLenient("mg")
+			So(err, ShouldBeNil)
+			So(replacements, ShouldBeEmpty)
+			_, _, err = parser.ParseLenient("µ€")
+			So(err, ShouldNotBeNil)
+			lenientParser := ucum.NewLenientExpressionParser(service.Model)
+			done := make(chan bool)
+			for _, unit := range []string{"µg", "m²", "°C", "kΩ"} {
+				go func(unit string) {
+					lenientParser.Parse(unit)
+					lenientParser.ParseLenient(unit)
+					done <- true
+				}(unit)
+			}
+			for i := 0; i < 4; i++ {
+				<-done
+			}
+		})
+	})
+}
+
+func TestRenderPrintSymbolTests(t *testing.T) {
+	InitService()
+	Convey("TestRenderPrintSymbolTests", t, func() {
+		render := func(unit string, style ucum.PrintStyle) string {
+			s, err := service.RenderPrintSymbol(unit, style)
+			So(err, ShouldBeNil)
+			return s
+		}
+		Convey("Unicode", func() {
+			So(render("mg/dL", ucum.PRINT_UNICODE), ShouldEqual, "mg/dL")
+			So(render("m2", ucum.PRINT_UNICODE), ShouldEqual, "m²")
+			So(render("ug", ucum.PRINT_UNICODE), ShouldEqual, "μg")
+			So(render("Cel", ucum.PRINT_UNICODE), ShouldEqual, "°C")
+			So(render("10*9/L", ucum.PRINT_UNICODE), ShouldEqual, "10⁹/L")
+			So(render("m.s-2", ucum.PRINT_UNICODE), ShouldEqual, "m·s⁻²")
+			So(render("/min", ucum.PRINT_UNICODE), ShouldEqual, "1/min")
+			So(render("mg/(kg.d)", ucum.PRINT_UNICODE), ShouldEqual, "mg/(kg·d)")
+			So(render("4.[pi]", ucum.PRINT_UNICODE), ShouldEqual, "4·π")
+			So(render("mg{total}", ucum.PRINT_UNICODE), ShouldEqual, "mg{total}")
+			So(render("{rbc}/uL", ucum.PRINT_UNICODE), ShouldEqual, "{rbc}/μL")
+			So(render("10*3{RBC}/uL", ucum.PRINT_UNICODE), ShouldEqual, "10³{RBC}/μL")
+			So(render("{beats}.min-1", ucum.PRINT_UNICODE), ShouldEqual, "{beats}·min⁻¹")
+		})
+		Convey("HTML", func() {
+			So(render("m2", ucum.PRINT_HTML), ShouldEqual, "m<sup>2</sup>")
+			So(render("a_t", ucum.PRINT_HTML), ShouldEqual, "a<sub>t</sub>")
+			So(render("N.m", ucum.PRINT_HTML), ShouldEqual, "N&middot;m")
+			So(render("mg{total}/dL", ucum.PRINT_HTML), ShouldEqual, "mg{total}/dL")
+		})
+		Convey("LaTeX", func() {
+			So(render("m2", ucum.PRINT_LATEX), ShouldEqual, `\mathrm{m}^{2}`)
+			So(render("ug/dL", ucum.PRINT_LATEX), ShouldEqual, `\mathrm{\mu{}g}/\mathrm{dL}`)
+			So(render("Cel", ucum.PRINT_LATEX), ShouldEqual, `\mathrm{^{\circ}C}`)
+			So(render("%", ucum.PRINT_LATEX), ShouldEqual, `\mathrm{\%}`)
+			So(render("mg{total}", ucum.PRINT_LATEX), ShouldEqual, `\mathrm{mg}\mathrm{\{total\}}`)
+		})
+		Convey("ASCII", func() {
+			So(render("mg/dL", ucum.PRINT_ASCII), ShouldEqual, "mg/dL")
+			So(render("ug", ucum.PRINT_ASCII), ShouldEqual, "ug")
+			So(render("Cel", ucum.PRINT_ASCII), ShouldEqual, "Cel")
+			So(render("m.s-2", ucum.PRINT_ASCII), ShouldEqual, "m.s^-2")
+			So(render("10*3{RBC}/uL", ucum.PRINT_ASCII), ShouldEqual, "10^3{RBC}/uL")
+		})
+		_, err := service.RenderPrintSymbol("mg/", ucum.PRINT_UNICODE)
+		So(err, ShouldNotBeNil)
+		style, err := ucum.PrintStyleString("PRINT_LATEX")
+		So(err, ShouldBeNil)
+		So(style, ShouldEqual, ucum.PRINT_LATEX)
+		So(ucum.PRINT_HTML.String(), ShouldEqual, "PRINT_HTML")
+		var concept ucum.Concepter = service.Model.GetUnit("m")
+		_, instanceof := concept.(ucum.MarkupConcepter)
+		So(instanceof, ShouldBeTrue)
+	})
+}
+
 func TestSearchPrefixTests(t *testing.T) {
 	InitService()
 	Convey("TestSearchPrefixTests", t, func() {
@@ -229,6 +854,96 @@ func TestSearchPrefixTests(t *testing.T) {
 	})
 }
 
+func TestSearchRankedTests(t *testing.T) {
+	InitService()
+	Convey("TestSearchRankedTests", t, func() {
+		list, err := service.SearchRanked("g", 0, 0)
+		So(err, ShouldBeNil)
+		So(list[0].Concept.GetCode(), ShouldEqual, "g")
+		So(list[0].Rank, ShouldEqual, ucum.MATCH_EXACT_CODE)
+		So(list[1].Rank, ShouldEqual, ucum.MATCH_EXACT_CODE_UC)
+		for i := 1; i < len(list); i++ {
+			So(list[i-1].Rank, ShouldBeGreaterThanOrEqualTo, list[i].Rank)
+		}
+		page, err := service.SearchRanked("g", 2, 3)
+		So(err, ShouldBeNil)
+		So(len(page), ShouldEqual, 3)
+		So(page[0].Concept, ShouldEqual, list[2].Concept)
+		list, err = service.SearchRanked("gram", 0, 0)
+		So(err, ShouldBeNil)
+		So(list[0].Concept.GetCode(), ShouldEqual, "g")
+		So(list[0].Rank, ShouldEqual, ucum.MATCH_PREFIX)
+		list, err = service.SearchRanked("mass concentration", 0, 0)
+		So(err, ShouldBeNil)
+		So(list[0].Concept.GetCode(), ShouldEqual, "g%")
+		So(list[0].Rank, ShouldEqual, ucum.MATCH_TOKEN)
+		list, err = service.SearchRanked("kelvn", 0, 0)
+		So(err, ShouldBeNil)
+		So(list[0].Concept.GetCode(), ShouldEqual, "K")
+		So(list[0].Rank, ShouldEqual, ucum.MATCH_FUZZY)
+		for _, typo := range []string{"kelvinn", "kelvim"} {
+			list, err = service.SearchRanked(typo, 0, 0)
+			So(err, ShouldBeNil)
+			So(list[0].Concept.GetCode(), ShouldEqual, "K")
+			So(list[0].Rank, ShouldEqual, ucum.MATCH_FUZZY)
+		}
+		done := make(chan bool)
+		for i := 0; i < 4; i++ {
+			go func() {
+				service.SearchRanked("kelvn", 0, 1)
+				service.SearchUnits("milligram per deciliter", true, 0, 1)
+				done <- true
+			}()
+		}
+		for i := 0; i < 4; i++ {
+			<-done
+		}
+		_, err = service.SearchRanked(" ", 0, 0)
+		So(err, ShouldNotBeNil)
+		_, err = service.Search(ucum.UNIT, "m([a-z]+r", true)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestSearchPrefixedUnitsTests(t *testing.T) {
+	InitService()
+	Convey("TestSearchPrefixedUnitsTests", t, func() {
+		list, err := service.SearchUnits("mg", false, 0, 0)
+		So(err, ShouldBeNil)
+		So(list[0].Concept.GetCode(), ShouldEqual, "mg")
+		So(list[0].Rank, ShouldEqual, ucum.MATCH_EXACT_CODE)
+		So(list[0].Concept.GetNames()[0], ShouldEqual, "milligram")
+		So(list[0].Concept.(ucum.Uniter).GetProperty(), ShouldEqual, "mass")
+		list, err = service.SearchUnits("kPa", false, 0, 0)
+		So(err, ShouldBeNil)
+		So(list[0].Concept.GetCode(), ShouldEqual, "kPa")
+		list, err = service.SearchUnits("uL", false, 0, 0)
+		So(err, ShouldBeNil)
+		So(list[0].Concept.GetCode(), ShouldEqual, "uL")
+		So(list[0].Concept.GetPrintSymbol(), ShouldEqual, "μL")
+		list, err = service.SearchUnits("milligram", false, 0, 0)
+		So(err, ShouldBeNil)
+		So(list[0].Concept.GetCode(), ShouldEqual, "mg")
+		So(list[0].Rank, ShouldEqual, ucum.MATCH_PREFIX)
+		list, err = service.SearchUnits("milligram per deciliter", false, 0, 0)
+		So(err, ShouldBeNil)
+		for _, r := range list {
+			So(r.Concept.GetCode(), ShouldNotEqual, "mg/dL")
+		}
+		list, err = service.SearchUnits("milligram per deciliter", true, 0, 0)
+		So(err, ShouldBeNil)
+		So(list[0].Concept.GetCode(), ShouldEqual, "mg/dL")
+		So(list[0].Concept.GetNames()[0], ShouldEqual, "milligram per deciliter")
+		list, err = service.SearchUnits("mg/dL", true, 0, 1)
+		So(err, ShouldBeNil)
+		So(len(list), ShouldEqual, 1)
+		So(list[0].Concept.GetCode(), ShouldEqual, "mg/dL")
+		So(list[0].Rank, ShouldEqual, ucum.MATCH_EXACT_CODE)
+		_, err = service.SearchUnits("", true, 0, 0)
+		So(err, ShouldNotBeNil)
+	})
+}
+
 func TestUcumValidateUCUMTest(t *testing.T) {
 	InitService()
 	Convey("TestUcumValidateUCUMTest", t, func() {
@@ -245,6 +960,247 @@ func TestUcumIdentificationTest(t *testing.T) {
 	Convey("TestUcumIdentificationTest", t, func() {
 		So(service.UcumIdentification().Version, ShouldNotBeEmpty)
 		So(service.UcumIdentification().ReleaseDate.String(), ShouldNotBeEmpty)
+		So(service.UcumIdentification().ReleaseDate.Format("2006-01-02"), ShouldEqual, "2017-11-21")
+		So(service.UcumIdentification().Revision, ShouldEqual, 442)
+	})
+}
+
+func TestStrictLoadingTest(t *testing.T) {
+	Convey("TestStrictLoadingTest", t, func() {
+		definitions := os.Getenv("GOPATH") + "/src/github.com/bertverhees/ucum/terminology_data/ucum-essence.xml"
+		strictService, err := ucum.NewUcumEssenceService(definitions, ucum.NewStrictDefinitionParser(false))
+		So(err, ShouldBeNil)
+		So(len(strictService.Model.DefinedUnits), ShouldBeGreaterThan, 0)
+		_, err = ucum.NewUcumEssenceService(definitions, ucum.NewStrictDefinitionParser(true))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "No handler for")
+		content, err := ioutil.ReadFile(definitions)
+		So(err, ShouldBeNil)
+		corrupt := strings.NewReplacer(
+			`<base-unit Code="cd" CODE="CD"`, `<base-unit Code="cdx" CODE="CDX"`,
+			`Code="T" CODE="T"`, `Code="Pa" CODE="T"`,
+			`<value Unit="d" UNIT="D" value="365.24219">`, `<value Unit="dd" UNIT="DD" value="365.24219">`,
+		).Replace(string(content))
+		_, err = new(ucum.DefinitionParser).UnmarshalTerminology(strings.NewReader(corrupt))
+		So(err, ShouldBeNil)
+		_, err = ucum.NewStrictDefinitionParser(false).UnmarshalTerminology(strings.NewReader(corrupt))
+		So(err, ShouldNotBeNil)
+		integrityError, instanceof := err.(*ucum.IntegrityError)
+		So(instanceof, ShouldBeTrue)
+		So(err.Error(), ShouldContainSubstring, "missing base unit cd")
+		So(err.Error(), ShouldContainSubstring, "duplicate code Pa")
+		So(err.Error(), ShouldContainSubstring, "unit a_t refers to an unknown unit")
+		So(len(integrityError.Problems), ShouldBeGreaterThan, 3)
+		corrupt = strings.Replace(string(content), `<name>liter</name>`, ``, -1)
+		corrupt = strings.Replace(corrupt, `ucum-essence"`, `ucum-other"`, 1)
+		_, err = ucum.NewStrictDefinitionParser(false).UnmarshalTerminology(strings.NewReader(corrupt))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "unit l has no name")
+		So(err.Error(), ShouldContainSubstring, "namespace")
+	})
+}
+
+func TestOverlayTest(t *testing.T) {
+	Convey("TestOverlayTest", t, func() {
+		definitions := os.Getenv("GOPATH") + "/src/github.com/bertverhees/ucum/terminology_data/ucum-essence.xml"
+		resources := os.Getenv("GOPATH") + "/src/github.com/bertverhees/ucum/convey/resources/"
+		localService, err := ucum.NewUcumEssenceService(definitions, new(ucum.DefinitionParser))
+		So(err, ShouldBeNil)
+		count := len(localService.Model.DefinedUnits)
+		valid, msg := localService.Validate("[drp_local]/min")
+		So(valid, ShouldBeFalse)
+		So(localService.LoadOverlay(resources+"overlay.xml"), ShouldBeNil)
+		So(localService.LoadOverlay(resources+"overlay.json"), ShouldBeNil)
+		So(len(localService.Model.DefinedUnits), ShouldEqual, count+3)
+		So(len(localService.Model.GetLocalUnits()), ShouldEqual, 3)
+		drop := localService.Model.GetUnit("[drp_local]").(*ucum.DefinedUnit)
+		So(drop.Provenance, ShouldEqual, "St. Elsewhere")
+		So(drop.IsLocal(), ShouldBeTrue)
+		So(localService.Model.GetUnit("[drp]").(*ucum.DefinedUnit).IsLocal(), ShouldBeFalse)
+		valid, msg = localService.Validate("[drp_local]/min")
+		So(valid, ShouldBeTrue)
+		So(msg, ShouldEqual, "non-standard: [drp_local] (St. Elsewhere)")
+		valid, msg = localService.Validate("[beats]/min")
+		So(valid, ShouldBeTrue)
+		So(msg, ShouldEqual, "non-standard: [beats] (lab system)")
+		valid, msg = localService.Validate("mL/min")
+		So(valid, ShouldBeTrue)
+		So(msg, ShouldEqual, "")
+		value, err := localService.Convert(decimal.New(20, 0), "[drp_local]", "mL")
+		So(err, ShouldBeNil)
+		So(value.Cmp(decimal.New(1, 0)), ShouldEqual, 0)
+		comparable, err := localService.IsComparable("[sth'U]", "[iU]")
+		So(err, ShouldBeNil)
+		So(comparable, ShouldBeFalse)
+		So(localService.GetClassInfo("local"), ShouldBeNil)
+		list, err := localService.SearchRanked("local drop", 0, 1)
+		So(err, ShouldBeNil)
+		So(list[0].Concept.GetCode(), ShouldEqual, "[drp_local]")
+		conflicting := `<overlay><unit Code="[drp]" CODE="[DRP]" class="local"><name>drop</name>
+			<property>volume</property><value Unit="mL" UNIT="ML" value="0.1"/></unit>
+			<unit Code="[x_local]" CODE="[X_LOCAL]" class="local"><name>x</name>
+			<property>volume</property><value Unit="[no_such_unit]" UNIT="[NO_SUCH_UNIT]" value="1"/></unit></overlay>`
+		err = new(ucum.DefinitionParser).MergeOverlay(localService.Model, strings.NewReader(conflicting))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "duplicate code [drp]")
+		So(err.Error(), ShouldContainSubstring, "[x_local] refers to an unknown unit")
+		So(len(localService.Model.DefinedUnits), ShouldEqual, count+3)
+		So(localService.Model.GetUnit("[x_local]"), ShouldBeNil)
+		for _, value := range []string{``, `, "value": ""`, `, "value": "0"`, `, "value": "-1"`} {
+			invalid := `{"units": [{"code": "[y_local]", "codeUC": "[Y_LOCAL]", "names": ["y"], "property": "volume",
+				"value": {"unit": "mL", "unitUC": "ML"` + value + `}}]}`
+			err = new(ucum.DefinitionParser).MergeOverlayJSON(localService.Model, strings.NewReader(invalid))
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "[y_local]")
+		}
+		invalid := `<overlay><unit Code="[y_local]" CODE="[Y_LOCAL]" class="local"><name>y</name>
+			<property>volume</property><value Unit="mL" UNIT="ML"/></unit></overlay>`
+		err = new(ucum.DefinitionParser).MergeOverlay(localService.Model, strings.NewReader(invalid))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "must have a positive value")
+		So(localService.Model.GetUnit("[y_local]"), ShouldBeNil)
+	})
+}
+
+func TestDiffVersionTest(t *testing.T) {
+	InitService()
+	Convey("TestDiffVersionTest", t, func() {
+		definitions := os.Getenv("GOPATH") + "/src/github.com/bertverhees/ucum/terminology_data/ucum-essence.xml"
+		diff, err := service.DiffVersion(definitions)
+		So(err, ShouldBeNil)
+		So(diff.IsEmpty(), ShouldBeTrue)
+		content, err := ioutil.ReadFile(definitions)
+		So(err, ShouldBeNil)
+		changed := strings.NewReplacer(
+			`version="2.1"`, `version="2.2"`,
+			`<unit Code="[lton_av]" CODE="[LTON_AV]"`, `<unit Code="[ton_br]" CODE="[TON_BR]"`,
+			`<value Unit="[ft_i]" UNIT="[FT_I]" value="3">3</value>`, `<value Unit="[ft_i]" UNIT="[FT_I]" value="4">4</value>`,
+			`<unit Code="[Ch]" CODE="[CH]" isMetric="no" class="clinical">`, `<unit Code="[Ch]" CODE="[CH]" isMetric="no" class="misc">`,
+			`<unit Code="[pi]" CODE="[PI]"`, `<unit Code="[pie]" CODE="[PIE]"`,
+			`<name>the number pi</name>`, `<name>the number pie</name>`,
+		).Replace(string(content))
+		file, err := ioutil.TempFile("", "ucum-essence-*.xml")
+		So(err, ShouldBeNil)
+		defer os.Remove(file.Name())
+		_, err = file.WriteString(changed)
+		So(err, ShouldBeNil)
+		file.Close()
+		diff, err = service.DiffVersion(file.Name())
+		So(err, ShouldBeNil)
+		So(diff.OldVersion, ShouldEqual, "2.1")
+		So(diff.NewVersion, ShouldEqual, "2.2")
+		So(len(diff.Renamed), ShouldEqual, 1)
+		So(diff.Renamed[0].OldCode, ShouldEqual, "[lton_av]")
+		So(diff.Renamed[0].NewCode, ShouldEqual, "[ton_br]")
+		So(len(diff.Removed), ShouldEqual, 1)
+		So(diff.Removed[0].GetCode(), ShouldEqual, "[pi]")
+		So(len(diff.Added), ShouldEqual, 1)
+		So(diff.Added[0].GetCode(), ShouldEqual, "[pie]")
+		changes := diff.GetChanges(ucum.UNIT, "[yd_i]")
+		So(len(changes), ShouldEqual, 2)
+		So(changes[0].Field, ShouldEqual, "canonical")
+		So(changes[1].Field, ShouldEqual, "value")
+		So(changes[1].Old, ShouldEqual, "3")
+		So(changes[1].New, ShouldEqual, "4")
+		//units defined with [yd_i] change canonical form too
+		changes = diff.GetChanges(ucum.UNIT, "[syd_i]")
+		So(len(changes), ShouldEqual, 1)
+		So(changes[0].Field, ShouldEqual, "canonical")
+		So(len(diff.GetChanges(ucum.UNIT, "[mi_i]")), ShouldEqual, 0)
+		changes = diff.GetChanges(ucum.UNIT, "[Ch]")
+		So(len(changes), ShouldEqual, 1)
+		So(changes[0].Field, ShouldEqual, "class")
+		So(diff.String(), ShouldContainSubstring, "~ unit [Ch] class: \"clinical\" -> \"misc\"")
+	})
+}
+
+func TestSnapshotTest(t *testing.T) {
+	InitService()
+	Convey("TestSnapshotTest", t, func() {
+		dir, err := ioutil.TempDir("", "ucum")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		for _, name := range []string{"snapshot.json", "snapshot.bin"} {
+			fileName := dir + "/" + name
+			index := service.Model.SearchIndex
+			So(service.SaveSnapshot(fileName), ShouldBeNil)
+			So(service.Model.SearchIndex, ShouldPointTo, index)
+			restored, err := ucum.NewUcumEssenceServiceFromSnapshot(fileName, "2.1")
+			So(err, ShouldBeNil)
+			So(ucum.DiffModels(service.Model, restored.Model, nil).IsEmpty(), ShouldBeTrue)
+			So(restored.UcumIdentification().ReleaseDate.Equal(service.UcumIdentification().ReleaseDate), ShouldBeTrue)
+			So(restored.GetProperties(), ShouldResemble, service.GetProperties())
+			So(restored.GetClassInfo("clinical"), ShouldResemble, service.GetClassInfo("clinical"))
+			value, err := restored.Convert(decimal.New(1, 0), "[lb_av]", "g")
+			So(err, ShouldBeNil)
+			expected, _ := service.Convert(decimal.New(1, 0), "[lb_av]", "g")
+			So(value.Cmp(expected), ShouldEqual, 0)
+			value, err = restored.Convert(decimal.New(1, 0), "[in_i]", "cm")
+			So(err, ShouldBeNil)
+			So(value.String(), ShouldEqual, "2.54")
+			value, err = restored.Convert(decimal.New(1, 0), "Cel", "K")
+			So(err, ShouldBeNil)
+			expected, _ = service.Convert(decimal.New(1, 0), "Cel", "K")
+			So(value.Cmp(expected), ShouldEqual, 0)
+			So(restored.ValidateUCUM(), ShouldResemble, service.ValidateUCUM())
+			comparable, err := restored.IsComparable("[iU]", "[arb'U]")
+			So(err, ShouldBeNil)
+			So(comparable, ShouldBeFalse)
+			list, err := restored.SearchRanked("kelvn", 0, 1)
+			So(err, ShouldBeNil)
+			So(list[0].Concept.GetCode(), ShouldEqual, "K")
+			list, err = restored.SearchUnits("milligram per deciliter", true, 0, 1)
+			So(err, ShouldBeNil)
+			So(list[0].Concept.GetCode(), ShouldEqual, "mg/dL")
+			So(restored.SaveSnapshot(fileName+".again"), ShouldBeNil)
+			again, err := ucum.NewUcumEssenceServiceFromSnapshot(fileName+".again", "2.1")
+			So(err, ShouldBeNil)
+			value, err = again.Convert(decimal.New(1, 0), "[in_i]", "cm")
+			So(err, ShouldBeNil)
+			So(value.String(), ShouldEqual, "2.54")
+			_, err = ucum.NewUcumEssenceServiceFromSnapshot(fileName, "3.0")
+			So(err, ShouldNotBeNil)
+		}
+		content, err := ioutil.ReadFile(dir + "/snapshot.json")
+		So(err, ShouldBeNil)
+		corrupted := strings.Replace(string(content), `"Code":"[lb_av]"`, `"Code":"[lb_xx]"`, 1)
+		So(corrupted, ShouldNotEqual, string(content))
+		_, err = ucum.ReadModelJSON(strings.NewReader(corrupted), "")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "checksum")
+	})
+}
+
+func TestRevisionParsingTest(t *testing.T) {
+	Convey("TestRevisionParsingTest", t, func() {
+		d, err := ucum.ParseRevisionDate("$Date: 2017-11-21 19:04:52 -0500 (Tue, 21 Nov 2017) $")
+		So(err, ShouldBeNil)
+		So(d.UTC().Format(time.RFC3339), ShouldEqual, "2017-11-22T00:04:52Z")
+		d, err = ucum.ParseRevisionDate("$Date: 2013-10-21T21:24:43-07:00 (Mon, 21 Oct 2013) $")
+		So(err, ShouldBeNil)
+		So(d.Format("2006-01-02"), ShouldEqual, "2013-10-21")
+		d, err = ucum.ParseRevisionDate("2015-11-13")
+		So(err, ShouldBeNil)
+		So(d.Format("2006-01-02"), ShouldEqual, "2015-11-13")
+		d, err = ucum.ParseRevisionDate("$Date$")
+		So(err, ShouldBeNil)
+		So(d.IsZero(), ShouldBeTrue)
+		d, err = ucum.ParseRevisionDate("")
+		So(err, ShouldBeNil)
+		So(d.IsZero(), ShouldBeTrue)
+		_, err = ucum.ParseRevisionDate("$Date: yesterday $")
+		So(err, ShouldNotBeNil)
+		r, err := ucum.ParseRevision("$Revision: 442 $")
+		So(err, ShouldBeNil)
+		So(r, ShouldEqual, 442)
+		r, err = ucum.ParseRevision("$Revision$")
+		So(err, ShouldBeNil)
+		So(r, ShouldEqual, 0)
+		_, err = ucum.ParseRevision("$Revision: abc $")
+		So(err, ShouldNotBeNil)
+		So(ucum.CompareVersions("2.1", "2.1"), ShouldEqual, 0)
+		So(ucum.CompareVersions("2.1", "2.10"), ShouldEqual, -1)
+		So(ucum.CompareVersions("2.1.1", "2.1"), ShouldEqual, 1)
 	})
 }
 
@@ -267,9 +1223,22 @@ func TestDisplayNameGenerationTest(t *testing.T) {
 			Convey(v.Id+": "+v.Unit, func() {
 				analysed, _ := service.Analyse(v.Unit)
 				So(analysed, ShouldEqual, v.Display)
+				So(service.GetCommonDisplay(v.Unit), ShouldEqual, v.Display)
 			})
 		}
 	})
+	Convey("TestDisplayNameGenerationNestedTest", t, func() {
+		So(service.GetCommonDisplay("mg/dL"), ShouldEqual, "(milligram) / (deciliter)")
+		So(service.GetCommonDisplay("mg/(kg.d)"), ShouldEqual, "(milligram) / ((kilogram) * (day))")
+		So(service.GetCommonDisplay("[not_a_unit]"), ShouldEqual, "not_a_unit")
+		So(service.GetCommonDisplay("mg{total}"), ShouldEqual, "(milligram)")
+		So(service.GetCommonDisplay("mg{total}/dL"), ShouldEqual, "(milligram) / (deciliter)")
+		So(service.GetCommonDisplay("/min"), ShouldEqual, "/ (minute)")
+		So(service.GetCommonDisplay("{beats}/min"), ShouldEqual, "/ (minute)")
+		So(service.GetCommonDisplay("10*3{RBC}/uL"), ShouldEqual, "(the number ten for arbitrary powers ^ 3) / (microliter)")
+		So(service.GetCommonDisplay("{cells}"), ShouldEqual, "(unity)")
+		So(service.GetCommonDisplay("mg/({x}.d)"), ShouldEqual, "(milligram) / ((day))")
+	})
 }
 
 func TestConversionTest(t *testing.T) {
@@ -305,6 +1274,150 @@ func TestConvert(t *testing.T){
 	})
 }
 
+func TestConvertWithMolarMass(t *testing.T) {
+	InitService()
+	Convey("TestConvertWithMolarMass", t, func() {
+		res, err := service.ConvertAnalyte(decimal.New(100, 0), "mg/dL", "mmol/L", "glucose")
+		So(err, ShouldBeNil)
+		So(res.Round(4).Cmp(decimal.RequireFromString("5.5507")), ShouldEqual, 0)
+		res, err = service.ConvertAnalyte(decimal.RequireFromString("5.5507"), "mmol/L", "mg/dL", "Glucose")
+		So(err, ShouldBeNil)
+		So(res.Round(1).Cmp(decimal.New(100, 0)), ShouldEqual, 0)
+		res, err = service.ConvertWithMolarMass(decimal.New(1, 0), "mol", "g", decimal.New(18, 0))
+		So(err, ShouldBeNil)
+		So(res.Round(6).Cmp(decimal.New(18, 0)), ShouldEqual, 0)
+		res, err = service.ConvertWithMolarMass(decimal.New(1, 0), "mg", "mg", decimal.New(18, 0))
+		So(err, ShouldBeNil)
+		So(res.Cmp(decimal.New(1, 0)), ShouldEqual, 0)
+		_, err = service.ConvertWithMolarMass(decimal.New(1, 0), "mg/dL", "mmol/s", decimal.New(18, 0))
+		So(err, ShouldNotBeNil)
+		_, err = service.ConvertAnalyte(decimal.New(1, 0), "mg/dL", "mmol/L", "unobtainium")
+		So(err, ShouldNotBeNil)
+		_, err = service.ConvertWithMolarMass(decimal.New(1, 0), "g", "1", decimal.New(180, 0))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "matching canonical forms")
+		_, err = service.ConvertWithMolarMass(decimal.New(1, 0), "mg", "g2", decimal.New(180, 0))
+		So(err, ShouldNotBeNil)
+		res, err = service.ConvertWithMolarMass(decimal.New(180, 0), "mg/dL", "meq/L", decimal.New(180, 0))
+		So(err, ShouldBeNil)
+		So(res.Round(6).Cmp(decimal.New(10, 0)), ShouldEqual, 0)
+		//the mole in a denominator
+		res, err = service.ConvertAnalyte(decimal.New(1, 0), "mg/mmol", "mg/g", "creatinine")
+		So(err, ShouldBeNil)
+		So(res.Round(4).Cmp(decimal.RequireFromString("8.8402")), ShouldEqual, 0)
+		res, err = service.ConvertAnalyte(decimal.RequireFromString("8.8402"), "mg/g", "mg/mmol", "creatinine")
+		So(err, ShouldBeNil)
+		So(res.Round(4).Cmp(decimal.New(1, 0)), ShouldEqual, 0)
+		res, err = service.ConvertWithMolarMass(decimal.New(1, 0), "mg/mmol", "g/mol", decimal.New(18, 0))
+		So(err, ShouldBeNil)
+		So(res.Cmp(decimal.New(1, 0)), ShouldEqual, 0)
+		res, err = service.ConvertWithMolarMass(decimal.New(9, 0), "g/mol", "1", decimal.New(18, 0))
+		So(err, ShouldBeNil)
+		So(res.Cmp(decimal.RequireFromString("0.5")), ShouldEqual, 0)
+		res, err = service.ConvertWithMolarMass(decimal.New(9, 0), "kg/mol", "1", decimal.New(18, 0))
+		So(err, ShouldBeNil)
+		So(res.Cmp(decimal.New(500, 0)), ShouldEqual, 0)
+		_, err = service.ConvertWithMolarMass(decimal.New(1, 0), "mg", "Tmol", decimal.RequireFromString("1e20"))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "underflows")
+	})
+}
+
+func TestConvertArbitraryUnits(t *testing.T) {
+	InitService()
+	Convey("TestConvertArbitraryUnits", t, func() {
+		_, err := service.Convert(decimal.New(10, 0), "[IU]", "ug")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "arbitrary")
+		res, err := service.ConvertAnalyte(decimal.New(10, 0), "[IU]", "ug", "insulin")
+		So(err, ShouldBeNil)
+		So(res.Cmp(decimal.New(347, 0)), ShouldEqual, 0)
+		res, err = service.ConvertAnalyte(decimal.New(1000, 0), "[iU]/L", "nmol/L", "vitamin D")
+		So(err, ShouldBeNil)
+		So(res.Round(2).Cmp(decimal.RequireFromString("65.00")), ShouldEqual, 0)
+		service.AnalyteFactors.Register("heparin", "[iU]", "ug", decimal.RequireFromString("5"))
+		res, err = service.ConvertAnalyte(decimal.New(2, 0), "mg", "k[IU]", "heparin")
+		So(err, ShouldBeNil)
+		So(res.Cmp(decimal.RequireFromString("0.4")), ShouldEqual, 0)
+		_, err = service.ConvertAnalyte(decimal.New(1, 0), "[arb'U]", "ug", "insulin")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "[arb'U]")
+	})
+}
+
+func TestArbitraryUnitSafety(t *testing.T) {
+	InitService()
+	Convey("TestArbitraryUnitSafety", t, func() {
+		arbitrary, err := service.IsArbitrary("[IU]/L")
+		So(err, ShouldBeNil)
+		So(arbitrary, ShouldBeTrue)
+		arbitrary, err = service.IsArbitrary("mg/L")
+		So(err, ShouldBeNil)
+		So(arbitrary, ShouldBeFalse)
+		comparable, err := service.IsComparable("[IU]", "[arb'U]")
+		So(err, ShouldBeNil)
+		So(comparable, ShouldBeFalse)
+		comparable, err = service.IsComparable("[CFU]", "1")
+		So(err, ShouldBeNil)
+		So(comparable, ShouldBeFalse)
+		comparable, err = service.IsComparable("[IU]/L", "m[iU]/mL")
+		So(err, ShouldBeNil)
+		So(comparable, ShouldBeTrue)
+		_, err = service.Convert(decimal.New(1, 0), "[IU]", "[arb'U]")
+		_, isArbitraryUnitError := err.(*ucum.ArbitraryUnitError)
+		So(isArbitraryUnitError, ShouldBeTrue)
+		res, err := service.Convert(decimal.New(1, 0), "k[IU]/L", "[iU]/mL")
+		So(err, ShouldBeNil)
+		So(res.Cmp(decimal.New(1, 0)), ShouldEqual, 0)
+	})
+}
+
+func TestDimensionlessPolicy(t *testing.T) {
+	InitService()
+	Convey("TestDimensionlessPolicy", t, func() {
+		defer func() { service.DimensionlessPolicy = ucum.STRICT_DIMENSIONLESS }()
+		res, err := service.Convert(decimal.New(1, 0), "%", "[ppm]")
+		So(err, ShouldBeNil)
+		So(res.Cmp(decimal.New(10000, 0)), ShouldEqual, 0)
+		comparable, _ := service.IsComparable("mol/mol", "g/g")
+		So(comparable, ShouldBeTrue)
+		comparable, _ = service.IsComparable("rad", "1")
+		So(comparable, ShouldBeFalse)
+		service.DimensionlessPolicy = ucum.LENIENT_DIMENSIONLESS
+		comparable, _ = service.IsComparable("rad", "1")
+		So(comparable, ShouldBeTrue)
+		comparable, _ = service.IsComparable("sr", "%")
+		So(comparable, ShouldBeTrue)
+		service.DimensionlessPolicy = ucum.RATIO_KIND_AWARE
+		comparable, _ = service.IsComparable("mol/mol", "g/g")
+		So(comparable, ShouldBeFalse)
+		comparable, _ = service.IsComparable("mmol/mol", "eq/eq")
+		So(comparable, ShouldBeTrue)
+		comparable, _ = service.IsComparable("mg/kg", "%")
+		So(comparable, ShouldBeTrue)
+		comparable, _ = service.IsComparable("mL/L", "mg/g")
+		So(comparable, ShouldBeFalse)
+		res, err = service.Convert(decimal.New(5, 0), "%", "[ppth]")
+		So(err, ShouldBeNil)
+		So(res.Cmp(decimal.New(50, 0)), ShouldEqual, 0)
+		_, err = service.Convert(decimal.New(5, 0), "mmol/mol", "mg/g")
+		So(err, ShouldNotBeNil)
+		src, _ := ucum.NewExpressionParser(service.Model).Parse("mmol/mol")
+		can, _ := ucum.NewConverter(service.Model, nil).Convert(src)
+		So(can.Dimensionless, ShouldBeTrue)
+		So(can.RatioKinds, ShouldResemble, []string{"mol"})
+		comparable, _ = service.IsComparable("L/L", "m/m")
+		So(comparable, ShouldBeFalse)
+		comparable, _ = service.IsComparable("mL/L", "cm3/m3")
+		So(comparable, ShouldBeTrue)
+		comparable, _ = service.IsComparable("km/m", "m/m")
+		So(comparable, ShouldBeTrue)
+		src, _ = ucum.NewExpressionParser(service.Model).Parse("mL/L")
+		can, _ = ucum.NewConverter(service.Model, nil).Convert(src)
+		So(can.RatioKinds, ShouldResemble, []string{"m3"})
+	})
+}
+
 func TestMultiplicationTest(t *testing.T) {
 	InitService()
 	Convey("TestMultiplicationTest", t, func() {
@@ -357,7 +1470,7 @@ func TestListAllProperties(t *testing.T) {
 		fmt.Errorf(err.Error())
 	}
 	properties := service.ListAllProperties()
-	if len(properties) !=99 {
+	if len(properties) !=100 {
 		t.Error("Not the right number of properties found")
 	}
 }
@@ -521,3 +1634,42 @@ func TestFilterDefinedUnits(t *testing.T){
 
 }
 
+
+func TestQueryDefinedUnits(t *testing.T) {
+	InitService()
+	Convey("TestQueryDefinedUnits", t, func() {
+		definedUnits, err := service.QueryDefinedUnits("class:iso1000 AND property:length AND metric:yes")
+		So(err, ShouldBeNil)
+		So(len(definedUnits), ShouldEqual, len(service.FilterDefinedModels("iso1000", "length", true, true, false, false, false, false)))
+		predicate := ucum.And(ucum.ByClass("iso1000"), ucum.Not(ucum.ByIsMetric(true)))
+		So(len(service.FilterDefinedUnits(predicate)), ShouldEqual, len(service.FilterDefinedModels("iso1000", "", true, false, false, false, false, false)))
+		definedUnits, err = service.QueryDefinedUnits("class:chemical AND metric:yes AND dim:M.L-3")
+		So(err, ShouldBeNil)
+		So(len(definedUnits), ShouldBeGreaterThan, 0)
+		for _, du := range definedUnits {
+			So(du.Class, ShouldEqual, "chemical")
+			So(du.Metric, ShouldBeTrue)
+			canonical, _ := service.GetCanonicalUnits(du.Code)
+			So(canonical, ShouldEqual, "g.m-3")
+		}
+		definedUnits, err = service.QueryDefinedUnits("dim:L-3.M AND canonical:kg/L")
+		So(err, ShouldBeNil)
+		So(len(definedUnits), ShouldBeGreaterThan, 0)
+		definedUnits, err = service.QueryDefinedUnits(`(property:length OR property:"mass concentration") AND NOT special:yes AND name:^meter`)
+		So(err, ShouldBeNil)
+		So(len(definedUnits), ShouldEqual, 0)
+		definedUnits, err = service.QueryDefinedUnits(`name:"^degree" AND printsymbol:yes`)
+		So(err, ShouldBeNil)
+		for _, du := range definedUnits {
+			So(du.PrintSymbol, ShouldNotBeEmpty)
+		}
+		_, err = service.QueryDefinedUnits("class:clinical AND")
+		So(err, ShouldNotBeNil)
+		_, err = service.QueryDefinedUnits("colour:red")
+		So(err, ShouldNotBeNil)
+		_, err = service.QueryDefinedUnits("(metric:yes")
+		So(err, ShouldNotBeNil)
+		_, err = service.QueryDefinedUnits("metric:maybe")
+		So(err, ShouldNotBeNil)
+	})
+}